@@ -4,14 +4,18 @@ import (
 	"bufio"
 	"context"
 	"encoding/csv"
+	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
+	"net/http"
 	"os"
 	"strings"
 	"time"
 
 	"github.com/google/go-github/v48/github"
 	"github.com/owasp-foundation/admin-local-go/shared"
+	"github.com/owasp-foundation/admin-local-go/shared/coppertest"
 	"github.com/stripe/stripe-go/v73"
 	"github.com/stripe/stripe-go/v73/customer"
 	"golang.org/x/oauth2"
@@ -42,172 +46,247 @@ func get_address_as_string(address shared.CopperAddress) string {
 	return address_string
 }
 
-func get_copper_person(email string) shared.CopperPerson {
-	cp, _ := shared.CopperFindPersonByEmailObj(email)
+// set by load_copper_cache; when nil people are looked up in Copper one at a time
+var copper_cache *shared.CopperPeopleCache
 
-	return cp
+func load_copper_cache(full bool) (*shared.CopperPeopleCache, error) {
+	cache, err := shared.LoadCopperPeopleCache(shared.GetConfigValue("COPPER_CACHE_FILE", "copper_people_cache.json"))
+	if err != nil {
+		return nil, err
+	}
+	err = cache.Refresh(full)
+	if err != nil {
+		return nil, err
+	}
+	err = cache.Save()
+
+	return cache, err
 }
 
-func fill_member_row_data(row []string, person shared.CopperPerson, customer *stripe.Customer, metadata map[string]string) []string {
-	firstName := strings.TrimSpace(person.FirstName)
-	lastName := strings.TrimSpace(person.LastName)
-	if firstName == "" { // no first name, get both first and last from Stripe Customer
-		names := strings.Split(customer.Name, " ")
-		if len(names) >= 1 {
-			for i, v := range names {
-				if i == 0 {
-					firstName = v
-				} else {
-					lastName = v + " "
-				}
-			}
-			lastName = strings.TrimRight(lastName, " ")
-		}
-	} else if lastName == "" {
-		names := strings.Split(customer.Name, " ")
-		if len(names) >= 1 {
-			for i, v := range names {
-				if i > 0 {
-					lastName = v + " "
-				}
-			}
-			lastName = strings.TrimRight(lastName, " ")
-		}
+// use_copper_cache makes get_copper_person use the refreshed local cache when it can be loaded
+func use_copper_cache() {
+	cache, err := load_copper_cache(false)
+	if err != nil {
+		fmt.Println("Could not load Copper cache, looking people up individually: " + err.Error())
+	} else {
+		copper_cache = cache
 	}
+}
 
-	row = append(row, firstName)
-	row = append(row, lastName)
-	emailstr := ""
-	owasp_email := ""
-	for _, email := range person.Emails {
-		if strings.Contains(email.Email, "@owasp.org") {
-			owasp_email = email.Email
-			emailstr = owasp_email
-			break
-		}
+// get_copper_person finds the person with the email, an empty person when there is none. The error
+// is a failed Copper lookup, where the person may well exist.
+func get_copper_person(email string) (shared.CopperPerson, error) {
+	if copper_cache != nil {
+		cp, _ := copper_cache.FindPersonByEmail(email)
+		return cp, nil
+	}
+	if strings.TrimSpace(email) == "" {
+		return shared.CopperPerson{}, nil
 	}
 
-	if owasp_email == "" {
-		meta_email := metadata["owasp_email"]
-		if strings.TrimSpace(meta_email) != "" {
-			owasp_email = strings.TrimSpace(meta_email)
-			emailstr = owasp_email
-		}
+	return shared.CopperFindPersonByEmailObj(email)
+}
+
+// list_member_customers returns every Stripe customer that has membership metadata
+func list_member_customers() ([]*stripe.Customer, error) {
+	stripe.Key = shared.GetConfigValue("STRIPE_SECRET", "")
+	params := &stripe.CustomerSearchParams{}
+	params.Query = *stripe.String("-metadata['membership_type']:null")
+
+	customers := make([]*stripe.Customer, 0)
+	iter := customer.Search(params)
+	for iter.Next() {
+		customers = append(customers, iter.Customer())
 	}
 
-	for _, email := range person.Emails {
-		if email.Email == owasp_email {
-			continue
-		} else if emailstr != "" {
-			emailstr += "\n"
-		}
+	return customers, iter.Err()
+}
+
+func get_member_name(person shared.CopperPerson, customer *stripe.Customer) (string, string) {
+	name := shared.MemberName(person, customer.Name)
 
-		emailstr += email.Email
+	return name.First, name.Last
+}
+
+// get_member_emails lists a member's valid addresses one per line, the primary one first
+func get_member_emails(person shared.CopperPerson, customer *stripe.Customer, metadata map[string]string) string {
+	return strings.Join(shared.ResolveMemberEmails(person, customer.Email, metadata["owasp_email"]).All(), "\n")
+}
+
+func export_members_for_ym(args []string) {
+	flags := flag.NewFlagSet("export_members", flag.ExitOnError)
+	nocache := flags.Bool("nocache", false, "look up each customer in Copper instead of using the local cache")
+	asof_str := flags.String("asof", "", "export the members active on this date instead of today")
+	subscriptions := flags.Bool("subscriptions", false, "add columns comparing membership_recurring with the customer's Stripe subscriptions")
+	columns_file := flags.String("columns", shared.GetConfigValue("YM_COLUMNS_FILE", ""), "json file mapping YourMembership columns to member fields, the built in layout when empty")
+	delta := flags.Bool("delta", false, "only write members that are new, changed or expired since the last export")
+	workers := flags.Int("workers", 4, "Copper lookups run at once")
+	rate := flags.Int("rate", 3, "maximum Copper lookups started per second when not using the cache")
+	address := flags.String("address", "raw", "address columns as entered in Copper (raw), normalized to ISO codes (normalized) or both")
+	phones := flags.String("phones", "raw", "phone numbers as entered in Copper (raw), in E.164 with categories and issues (e164) or both")
+	flags.Parse(args)
+
+	columns, err := load_member_columns(*columns_file)
+	if err != nil {
+		fmt.Println("Could not load columns from " + *columns_file + ": " + err.Error())
+		return
 	}
-	if !strings.Contains(emailstr, customer.Email) {
-		if emailstr != "" {
-			emailstr += "\n"
-		}
-		emailstr += customer.Email
+	columns, err = address_columns(columns, *address)
+	if err == nil {
+		columns, err = phone_columns(columns, *phones)
+	}
+	if err != nil {
+		fmt.Println(err.Error())
+		return
 	}
-	row = append(row, emailstr)
-	phonestr := ""
-	for _, phone := range person.PhoneNumbers {
-		if phonestr != "" {
-			phonestr += "\n"
+
+	asof := time.Now()
+	if *asof_str != "" {
+		asof, err = shared.StringToDateTimeHelper(*asof_str)
+		if err != nil {
+			fmt.Println("Could not parse -asof date " + *asof_str)
+			return
 		}
-		phonestr += phone.Number
 	}
-	row = append(row, phonestr)
-	row = append(row, person.Address.Street)
-	row = append(row, person.Address.City)
-	row = append(row, person.Address.State)
-	row = append(row, person.Address.Country)
-	row = append(row, person.Address.PostalCode)
-	row = append(row, metadata["membership_type"])
-	row = append(row, metadata["membership_start"])
-	row = append(row, metadata["membership_end"])
-	row = append(row, metadata["membership_recurring"])
 
-	github := shared.CopperGetCustomFieldValue(person.CustomFields, shared.CP_person_github_username)
-	row = append(row, fmt.Sprintf("%v", github))
+	fmt.Println("Exporting members to csv")
 
-	tagstr := ""
-	for _, tag := range person.Tags {
-		tagstr += tag
-		tagstr += "\n"
+	if !*nocache {
+		use_copper_cache()
 	}
 
-	row = append(row, tagstr)
-
-	return row
-}
+	tiers, err := shared.ConfiguredMembershipTiers()
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+	member_data := shared.MemberData{
+		AsOf:         asof.Format("2006-01-02"),
+		ExpiryCutoff: shared.MembershipExpiryCutoff(asof).Format(time.RFC3339),
+	}
 
-func export_members_for_ym() {
-	fmt.Println("Exporting members to csv")
+	records := [][]string{
+		member_column_header(columns),
+	}
+	if *subscriptions {
+		records[0] = append(records[0], "stripe_auto_renew", "stripe_next_renewal", "stripe_payment_status", "recurring_matches_stripe")
+	}
 
-	member_data := shared.MemberData{}
+	state_file := shared.GetConfigValue("YM_EXPORT_STATE_FILE", "ym_export_state.json")
+	previous, err := load_member_export_state(state_file)
+	if err != nil {
+		fmt.Println("Could not read last export state " + state_file + ": " + err.Error())
+		return
+	}
+	if *delta && len(previous.Members) > 0 && !same_row(previous.Columns, records[0]) {
+		fmt.Println("The columns changed since the export as of " + previous.AsOf + ", run a full export first")
+		return
+	}
+	ids := make([]string, 0)
+	rows := make(map[string][]string)
+	members := make([]*stripe.Customer, 0)
+	memberships := make([]shared.Membership, 0)
 
-	skey := shared.GetConfigValue("STRIPE_SECRET", "")
+	unmatched := [][]string{
+		{"customer_id", "email", "membership_type"},
+	}
 
-	stripe.Key = skey
-	params := &stripe.CustomerSearchParams{}
-	params.Query = *stripe.String("-metadata['membership_type']:null")
+	customers, err := list_member_customers()
+	if err != nil {
+		fmt.Println("Failed to list Stripe customers: " + err.Error())
+		return
+	}
+	for _, current := range customers {
+		membership, err := shared.ParseStripeMembership(current.Metadata)
+		if membership.Tier == shared.MembershipUnknown {
+			unmatched = append(unmatched, []string{current.ID, current.Email, membership.Type})
+			continue
+		} else if err != nil {
+			fmt.Println("Customer " + current.ID + ": " + err.Error())
+		}
+		if tiers.Contains(membership.Tier) && membership.ActiveOn(asof) {
+			member_data.Count(membership.Tier)
+			members = append(members, current)
+			memberships = append(memberships, membership)
+		}
+	}
 
-	iter := customer.Search(params)
-	expiry := time.Now().AddDate(0, 0, -1)
-	records := [][]string{
-		{"first_name", "last_name", "emails", "phone_numbers", "street_address", "city", "state", "country", "postal_code", "membership_type", "membership_start", "membership_end", "membership_recurring", "github_id", "tags"},
+	// the cache answers without calling Copper, so only direct lookups are rate limited
+	lookup_rate := *rate
+	if copper_cache != nil {
+		lookup_rate = 0
+	}
+	people, failed := enrich_member_customers(members, *workers, lookup_rate)
+	// rows of failed lookups lack their Copper fields, so they would show up as changed
+	if failed > 0 && *delta {
+		fmt.Printf("%d Copper lookups failed, no delta was written; run the export again\n", failed)
+		return
+	}
+	for i, current := range members {
+		row := fill_member_row_data(make([]string, 0), columns, people[i], current, current.Metadata)
+		if *subscriptions {
+			row = fill_subscription_row_data(row, current, memberships[i])
+		}
+		records = append(records, row)
+		ids = append(ids, current.ID)
+		rows[current.ID] = row
 	}
 
-	for iter.Next() {
-		row := make([]string, 0)
-		current := iter.Customer()
-		metadata := current.Metadata
-		var copper_person shared.CopperPerson
-		if metadata != nil {
-			member_type := strings.Trim(strings.ToLower(metadata["membership_type"]), " ")
-
-			if strings.Contains(member_type, "lifetime") {
-				member_data.Lifetime++
-				copper_person = get_copper_person(current.Email)
-				row = fill_member_row_data(row, copper_person, current, metadata)
-			} else {
-				member_end := metadata["membership_end"]
-				end_date, _ := shared.StringToDateTimeHelper(member_end)
-				if end_date.After(expiry) {
-					if strings.Contains(member_type, "one") {
-						member_data.One++
-						copper_person = get_copper_person(current.Email)
-						row = fill_member_row_data(row, copper_person, current, metadata)
-					} else if strings.Contains(member_type, "two") {
-						member_data.Two++
-						copper_person = get_copper_person(current.Email)
-						row = fill_member_row_data(row, copper_person, current, metadata)
-					} else if strings.Contains(member_type, "complimentary") {
-						member_data.Complimentary++
-						copper_person = get_copper_person(current.Email)
-						row = fill_member_row_data(row, copper_person, current, metadata)
-					}
-				}
+	timestamp := strings.ReplaceAll(time.Now().String(), " ", "_")
+	header := records[0]
+	var werr error
+	if *delta {
+		changes, counts := member_export_delta(previous, ids, rows)
+		fmt.Printf("Since the export as of %s: %d new, %d changed, %d expired\n", previous.AsOf, counts[member_new], counts[member_changed], counts[member_expired])
+		records = append([][]string{append(append([]string{}, header...), "change_type")}, changes...)
+		werr = write_csv_file(fmt.Sprintf("members_delta_asof_%s_%s.csv", member_data.AsOf, timestamp), records)
+	} else {
+		werr = write_csv_file(fmt.Sprintf("members_asof_%s_%s.csv", member_data.AsOf, timestamp), records)
+	}
+	// a backdated export is not what YourMembership holds, so it does not move the state
+	if failed > 0 {
+		fmt.Printf("%d Copper lookups failed, their rows have no Copper fields and the export state was not saved\n", failed)
+	} else if werr == nil && *asof_str == "" {
+		err = save_member_export_state(state_file, member_export_state{AsOf: member_data.AsOf, Columns: header, Members: rows})
+		if err != nil {
+			fmt.Println("Failed to save export state " + state_file + ": " + err.Error())
+			if *delta {
+				fmt.Println("Warning: the next -delta export will list these changes again")
 			}
 		}
-		if len(row) > 0 {
-			records = append(records, row)
-		}
 	}
+	write_json_file(fmt.Sprintf("member_counts_asof_%s_%s.json", member_data.AsOf, timestamp), member_data)
+	fmt.Printf("Members as of %s: one %d, two %d, lifetime %d, complimentary %d, student %d, honorary %d\n", member_data.AsOf,
+		member_data.One, member_data.Two, member_data.Lifetime, member_data.Complimentary, member_data.Student, member_data.Honorary)
+	if len(unmatched) > 1 {
+		fmt.Printf("%d customers have a membership_type matching no known tier\n", len(unmatched)-1)
+		write_csv_file(fmt.Sprintf("unknown_membership_types_%s.csv", timestamp), unmatched)
+	}
+	fmt.Println("Done")
+}
 
-	filename := fmt.Sprintf("members_%s.csv", strings.ReplaceAll(time.Now().String(), " ", "_"))
+func write_json_file(filename string, value interface{}) {
+	body, err := json.MarshalIndent(value, "", "  ")
+	if err == nil {
+		err = os.WriteFile(filename, body, 0644)
+	}
+	if err != nil {
+		fmt.Println("Failed to write file " + filename + ": " + err.Error())
+	}
+}
+
+func write_csv_file(filename string, records [][]string) error {
 	csv_file, ferr := os.Create(filename)
 
 	if ferr != nil {
-		fmt.Println("Failed to open file")
+		fmt.Println("Failed to open file " + filename)
 	} else {
 		defer csv_file.Close()
 		w := csv.NewWriter(csv_file)
-		w.WriteAll(records) // calls Flush internally
+		ferr = w.WriteAll(records) // calls Flush internally
 	}
-	fmt.Println("Done")
+
+	return ferr
 }
 
 func get_repos_matching(ctx context.Context, client *github.Client, match string) []*github.Repository {
@@ -555,6 +634,38 @@ func fill_project_row_data(client *github.Client, ctx context.Context, repo *git
 	return row
 }
 
+func refresh_copper_cache(args []string) {
+	flags := flag.NewFlagSet("refresh_copper_cache", flag.ExitOnError)
+	full := flags.Bool("full", false, "rebuild the cache from scratch, dropping people deleted in Copper")
+	flags.Parse(args)
+
+	fmt.Println("Refreshing Copper cache...")
+	_, err := load_copper_cache(*full)
+	if err != nil {
+		fmt.Println("Failed to refresh Copper cache: " + err.Error())
+		return
+	}
+	fmt.Println("Done")
+}
+
+func fake_copper(args []string) {
+	flags := flag.NewFlagSet("fake_copper", flag.ExitOnError)
+	fixtures_file := flags.String("fixtures", "copper_fixtures.json", "json file of people, opportunities and custom_field_definitions to serve")
+	addr := flags.String("addr", "127.0.0.1:8089", "address to listen on")
+	flags.Parse(args)
+
+	fixtures, err := coppertest.LoadFixtures(*fixtures_file)
+	if err != nil {
+		fmt.Println("Failed to load fixtures: " + err.Error())
+		return
+	}
+	fmt.Printf("Serving fake Copper API, set COPPER_BASE_URL: http://%s/developer_api/v1/ to use it\n", *addr)
+	err = http.ListenAndServe(*addr, coppertest.NewServer(fixtures))
+	if err != nil {
+		fmt.Println(err.Error())
+	}
+}
+
 func project_audit() {
 	fmt.Println("Performing audit...")
 
@@ -589,14 +700,105 @@ func project_audit() {
 	fmt.Println("Done")
 }
 
-// functions in this quick and dirty admin tool:
+// functions in this quick and dirty admin tool, run as `admin-local-go <command> [flags]`:
 //
-// # export_members_for_ym()
+// # export_members [-nocache] [-asof date] [-subscriptions] [-columns file] [-delta] [-workers n] [-rate n]
+// #   [-address raw|normalized|both] [-phones raw|e164|both]
 // # exports members from Stripe/Copper to a csv file to be imported into YourMembership
+// # -columns (or YM_COLUMNS_FILE) is a json list of {column, source, transform}, see member_columns.go
+// # -delta writes only members new, changed or expired since the last export (kept in YM_EXPORT_STATE_FILE)
+//
+// # member_stats [-from yyyy-mm] [-to yyyy-mm] [-format csv|json]
+// # writes active member counts per tier for each month, with the month over month change
+//
+// # membership_cohorts [-from yyyy-mm]
+// # groups members by start month and tier and reports retention at 12, 24 and 36 months
+//
+// # lapsed_members [-days n] [-nocache]
+// # lists members who lapsed in the last n days and did not renew, skipping WINBACK_OPTOUT_TAGS
 //
-// # project_audit
+// # find_duplicate_customers [-noname] [-nocache]
+// # clusters Stripe customers by email, Copper person and name and plans which one to keep; customers
+// # that only share a name are marked for review rather than merged
+//
+// # lint_members [-nosubscriptions]
+// # flags unparseable, ambiguous or inconsistent membership metadata on Stripe customers
+//
+// # correct_metadata [-file changes.csv] [-rules rules.json] [-apply] [-rate n]
+// # plans, and with -apply makes, bulk Stripe metadata changes, writing an undo file first
+//
+// # membership_revenue [-from date] [-to date]
+// # sums membership charges by tier, currency and refund status and compares payers with members
+//
+// # check_refunds [-from date] [-to date] [-apply] [-rate n]
+// # finds active memberships whose payment was refunded or charged back and plans shortening them
+//
+// # member_regions [-asof date] [-nocache] [-workers n] [-rate n]
+// # counts active members per chapter region and per country by tier, from their Copper address country
+//
+// # upcoming_expirations [-days n] [-nocache]
+// # lists members whose membership ends in the next n days, for renewal reminders
+//
+// # reconcile_members [-all]
+// # reports where Stripe membership metadata and Copper membership fields disagree
+//
+// # sync_copper_members [-apply]
+// # plans, and with -apply makes, updates to Copper membership fields from Stripe metadata
+//
+// # refresh_copper_cache [-full]
+// # updates the local copy of Copper people used by the member commands
+//
+// # fake_copper [-fixtures file] [-addr host:port]
+// # serves an in-memory Copper API for working offline, see shared/coppertest
+//
+// # project_audit (default)
 // # prepares a file which indicates project name, leaders, last website update, last commit, last issue, external links on website
 func main() {
-	project_audit()
-	//export_members_for_ym()
+	command := "project_audit"
+	var args []string
+	if len(os.Args) > 1 {
+		command = os.Args[1]
+		args = os.Args[2:]
+	}
+	if base_url := shared.GetConfigValue("COPPER_BASE_URL", ""); base_url != "" {
+		shared.CP_base_url = base_url
+	}
+
+	switch command {
+	case "export_members":
+		export_members_for_ym(args)
+	case "member_stats":
+		member_stats(args)
+	case "membership_cohorts":
+		membership_cohorts(args)
+	case "lapsed_members":
+		lapsed_members(args)
+	case "find_duplicate_customers":
+		find_duplicate_customers(args)
+	case "lint_members":
+		lint_members(args)
+	case "correct_metadata":
+		correct_metadata(args)
+	case "membership_revenue":
+		membership_revenue(args)
+	case "check_refunds":
+		check_refunds(args)
+	case "member_regions":
+		member_regions(args)
+	case "upcoming_expirations":
+		upcoming_expirations(args)
+	case "reconcile_members":
+		reconcile_members(args)
+	case "sync_copper_members":
+		sync_copper_members(args)
+	case "refresh_copper_cache":
+		refresh_copper_cache(args)
+	case "fake_copper":
+		fake_copper(args)
+	case "project_audit":
+		project_audit()
+	default:
+		fmt.Println("Unknown command " + command)
+		os.Exit(1)
+	}
 }
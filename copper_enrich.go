@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/owasp-foundation/admin-local-go/shared"
+	"github.com/stripe/stripe-go/v73"
+)
+
+// enrich_member_customers looks up each customer's Copper person with at most workers lookups in flight,
+// started no faster than rate per second (0 for no limit, as when the cache answers), and returns
+// the people in the same order as customers, with the number of lookups that failed
+func enrich_member_customers(customers []*stripe.Customer, workers int, rate int) ([]shared.CopperPerson, int) {
+	people := make([]shared.CopperPerson, len(customers))
+	failed := 0
+	var failed_lock sync.Mutex
+	if workers < 1 {
+		workers = 1
+	}
+
+	var limit <-chan time.Time
+	if rate > 0 {
+		ticker := time.NewTicker(time.Second / time.Duration(rate))
+		defer ticker.Stop()
+		limit = ticker.C
+	}
+
+	start := time.Now()
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				var err error
+				people[i], err = get_copper_person(customers[i].Email)
+				if err != nil {
+					fmt.Println("Copper lookup for " + customers[i].ID + " failed: " + err.Error())
+					failed_lock.Lock()
+					failed++
+					failed_lock.Unlock()
+				}
+			}
+		}()
+	}
+	for i := range customers {
+		if limit != nil {
+			<-limit
+		}
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	elapsed := time.Since(start)
+	if len(customers) > 0 && elapsed > 0 {
+		fmt.Printf("Looked up %d people in Copper in %s (%.1f per second, %d workers)\n", len(customers), elapsed.Round(time.Millisecond), float64(len(customers))/elapsed.Seconds(), workers)
+	}
+
+	return people, failed
+}
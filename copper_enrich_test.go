@@ -0,0 +1,35 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/owasp-foundation/admin-local-go/shared"
+	"github.com/owasp-foundation/admin-local-go/shared/coppertest"
+	"github.com/stripe/stripe-go/v73"
+)
+
+func TestEnrichMemberCustomers(t *testing.T) {
+	coppertest.Start(t, coppertest.TestFixtures())
+	customers := []*stripe.Customer{{ID: "cus_1", Email: "jane@example.com"}, {ID: "cus_2", Email: "nobody@example.com"}, {ID: "cus_3"}}
+
+	people, failed := enrich_member_customers(customers, 2, 0)
+	if failed != 0 || people[0].ID != 1 || people[1].ID != 0 || people[2].ID != 0 {
+		t.Errorf("got people %d %d %d with %d failed, want 1 0 0 with none failed", people[0].ID, people[1].ID, people[2].ID, failed)
+	}
+
+	// a Copper outage is a failure, not a customer without a Copper person
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "rate limited", http.StatusTooManyRequests)
+	}))
+	defer down.Close()
+	base_url := shared.CP_base_url
+	shared.CP_base_url = down.URL + "/"
+	defer func() { shared.CP_base_url = base_url }()
+
+	_, failed = enrich_member_customers(customers, 2, 0)
+	if failed != 2 {
+		t.Errorf("%d lookups failed, want 2", failed)
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/owasp-foundation/admin-local-go/shared"
+)
+
+var cohort_horizons = []int{12, 24, 36}
+
+type member_cohort struct {
+	Month    time.Time
+	Tier     shared.MembershipTier
+	Members  int
+	Eligible map[int]int // horizon months -> members whose horizon has passed
+	Renewed  map[int]int // bought membership again before the horizon
+	Retained map[int]int // still a member at the horizon
+}
+
+// cohort_member joins the Stripe membership with the person's Copper membership purchases;
+// the cohort is the month of the earliest start either of them knows about
+type cohort_member struct {
+	membership shared.Membership
+	purchases  []shared.CopperMembershipPurchase
+	start      time.Time
+}
+
+func (m cohort_member) active_at(t time.Time) bool {
+	if m.membership.ActiveOn(t) {
+		return true
+	}
+	for _, purchase := range m.purchases {
+		if purchase.Covers(t) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (m cohort_member) renewed_by(t time.Time) bool {
+	// anything bought more than a month after joining is a renewal rather than the original purchase
+	first_renewal := m.start.AddDate(0, 1, 0)
+	for _, purchase := range m.purchases {
+		if purchase.Closed.After(first_renewal) && !purchase.Closed.After(t) {
+			return true
+		}
+	}
+
+	return m.membership.Start.After(first_renewal) && !m.membership.Start.After(t)
+}
+
+func membership_cohorts(args []string) {
+	flags := flag.NewFlagSet("membership_cohorts", flag.ExitOnError)
+	from_str := flags.String("from", "", "first cohort month to report, yyyy-mm")
+	flags.Parse(args)
+
+	var from time.Time
+	if *from_str != "" {
+		var err error
+		from, err = parse_month(*from_str)
+		if err != nil {
+			fmt.Println("Could not parse -from month " + *from_str)
+			return
+		}
+	}
+	tiers, err := shared.ConfiguredMembershipTiers()
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+
+	fmt.Println("Computing membership cohort retention...")
+
+	customers, err := list_member_customers()
+	if err != nil {
+		fmt.Println("Failed to list Stripe customers: " + err.Error())
+		return
+	}
+	cache, err := load_copper_cache(false)
+	purchases := make(map[int][]shared.CopperMembershipPurchase)
+	if err == nil {
+		purchases, err = shared.CopperListMembershipPurchases()
+	}
+	if err != nil {
+		fmt.Println("Could not read Copper membership opportunities, using Stripe metadata only: " + err.Error())
+		cache = nil
+	}
+
+	now := time.Now()
+	cohorts := make(map[string]*member_cohort)
+	by_stripe_number := make(map[string][]int)
+	if cache != nil {
+		by_stripe_number = copper_people_by_stripe_number(cache)
+	}
+	for _, current := range customers {
+		membership, _ := shared.ParseStripeMembership(current.Metadata)
+		if !tiers.Contains(membership.Tier) {
+			continue
+		}
+		member := cohort_member{membership: membership, start: membership.Start}
+		if cache != nil {
+			if person, ok := find_copper_person_for_customer(cache, by_stripe_number, current); ok {
+				member.purchases = purchases[person.ID]
+			}
+		}
+		for _, purchase := range member.purchases {
+			if !purchase.Closed.IsZero() && (member.start.IsZero() || purchase.Closed.Before(member.start)) {
+				member.start = purchase.Closed
+			}
+		}
+		if member.start.IsZero() {
+			continue
+		}
+
+		month := time.Date(member.start.Year(), member.start.Month(), 1, 0, 0, 0, 0, time.UTC)
+		if month.Before(from) {
+			continue
+		}
+		key := month.Format("2006-01") + " " + membership.Tier.String()
+		cohort, ok := cohorts[key]
+		if !ok {
+			cohort = &member_cohort{Month: month, Tier: membership.Tier, Eligible: map[int]int{}, Renewed: map[int]int{}, Retained: map[int]int{}}
+			cohorts[key] = cohort
+		}
+		cohort.Members++
+		for _, horizon := range cohort_horizons {
+			at := member.start.AddDate(0, horizon, 0)
+			if at.After(now) {
+				continue
+			}
+			cohort.Eligible[horizon]++
+			if member.renewed_by(at) {
+				cohort.Renewed[horizon]++
+			}
+			if member.active_at(at) {
+				cohort.Retained[horizon]++
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(cohorts))
+	for key := range cohorts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := cohorts[keys[i]], cohorts[keys[j]]
+		if !a.Month.Equal(b.Month) {
+			return a.Month.Before(b.Month)
+		}
+		return a.Tier < b.Tier
+	})
+
+	header := []string{"cohort", "membership_type", "members"}
+	for _, horizon := range cohort_horizons {
+		header = append(header, fmt.Sprintf("eligible_%dm", horizon), fmt.Sprintf("renewed_%dm", horizon),
+			fmt.Sprintf("retained_%dm", horizon), fmt.Sprintf("retention_%dm_percent", horizon))
+	}
+	records := [][]string{header}
+	for _, key := range keys {
+		cohort := cohorts[key]
+		row := []string{cohort.Month.Format("2006-01"), cohort.Tier.String(), strconv.Itoa(cohort.Members)}
+		for _, horizon := range cohort_horizons {
+			rate := ""
+			if cohort.Eligible[horizon] > 0 {
+				rate = strconv.FormatFloat(float64(cohort.Retained[horizon])*100/float64(cohort.Eligible[horizon]), 'f', 1, 64)
+			}
+			row = append(row, strconv.Itoa(cohort.Eligible[horizon]), strconv.Itoa(cohort.Renewed[horizon]), strconv.Itoa(cohort.Retained[horizon]), rate)
+		}
+		records = append(records, row)
+	}
+
+	// the board's question: how do the tiers compare over all cohorts
+	for _, tier := range tiers {
+		summary := fmt.Sprintf("%s:", tier)
+		for _, horizon := range cohort_horizons {
+			eligible, retained := 0, 0
+			for _, cohort := range cohorts {
+				if cohort.Tier == tier {
+					eligible += cohort.Eligible[horizon]
+					retained += cohort.Retained[horizon]
+				}
+			}
+			if eligible > 0 {
+				summary += fmt.Sprintf(" %dm %.1f%% of %d", horizon, float64(retained)*100/float64(eligible), eligible)
+			}
+		}
+		fmt.Println(summary)
+	}
+
+	write_csv_file(fmt.Sprintf("membership_cohorts_%s.csv", strings.ReplaceAll(time.Now().String(), " ", "_")), records)
+	fmt.Println("Done")
+}
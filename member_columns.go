@@ -0,0 +1,388 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/owasp-foundation/admin-local-go/shared"
+	"github.com/stripe/stripe-go/v73"
+)
+
+// member_column is one column of the YourMembership file. Source names a field:
+//
+//	member.prefix, member.first_name, member.middle_name, member.last_name, member.suffix,
+//	  member.emails, member.primary_email, member.secondary_emails, member.invalid_emails - combined
+//	  from Copper and Stripe
+//	member.phone_numbers - the Copper phone numbers as entered, the same as copper.phone_numbers
+//	stripe.id, stripe.email, stripe.name, stripe.phone, stripe.country, stripe.postal_code
+//	metadata.<key> - Stripe customer metadata
+//	address.<field> - the Copper address normalized: street, city, state, state_code (as entered when
+//	  unknown), country, country_code, postal_code and issues
+//	phone.e164, phone.categories, phone.issues - the Copper phone numbers in E.164, dialed from the
+//	  address country, one per line with their categories on the same lines; numbers that could not be
+//	  normalized are kept as entered and listed in issues
+//	copper.<field> - first_name, last_name, name, emails, phone_numbers, street, city, state, country,
+//	  postal_code, tags, github_username, stripe_number, or custom.<custom field definition id>
+//
+// Transform is a | separated list applied in order: trim, upper, lower, title, date:<go layout>,
+// join:<separator> (replaces the newlines between multiple values) and default:<value>.
+type member_column struct {
+	Column    string `json:"column"`
+	Source    string `json:"source"`
+	Transform string `json:"transform,omitempty"`
+}
+
+// the layout YourMembership's import template had when this tool was written
+var default_member_columns = []member_column{
+	{Column: "first_name", Source: "member.first_name"},
+	{Column: "last_name", Source: "member.last_name"},
+	{Column: "emails", Source: "member.emails"},
+	{Column: "phone_numbers", Source: "member.phone_numbers"},
+	{Column: "street_address", Source: "copper.street"},
+	{Column: "city", Source: "copper.city"},
+	{Column: "state", Source: "copper.state"},
+	{Column: "country", Source: "copper.country"},
+	{Column: "postal_code", Source: "copper.postal_code"},
+	{Column: "membership_type", Source: "metadata.membership_type"},
+	{Column: "membership_start", Source: "metadata.membership_start"},
+	{Column: "membership_end", Source: "metadata.membership_end"},
+	{Column: "membership_recurring", Source: "metadata.membership_recurring"},
+	{Column: "github_id", Source: "copper.github_username"},
+	{Column: "tags", Source: "copper.tags"},
+}
+
+var copper_custom_field_names = map[string]int{
+	"github_username": shared.CP_person_github_username,
+	"stripe_number":   shared.CP_person_stripe_number,
+}
+
+// load_member_columns reads a json list of columns, or gives the default layout for an empty filename
+func load_member_columns(filename string) ([]member_column, error) {
+	if filename == "" {
+		return default_member_columns, nil
+	}
+
+	columns := make([]member_column, 0)
+	body, err := os.ReadFile(filename)
+	if err == nil {
+		err = json.Unmarshal(body, &columns)
+	}
+	if err != nil {
+		return columns, err
+	}
+
+	// catch mistakes before spending an export on them
+	empty := &stripe.Customer{}
+	for _, column := range columns {
+		if column.Column == "" {
+			return columns, fmt.Errorf("column with source %q has no name", column.Source)
+		}
+		if _, err := member_field(column.Source, shared.CopperPerson{}, empty, map[string]string{}); err != nil {
+			return columns, fmt.Errorf("column %s: %s", column.Column, err.Error())
+		}
+		if _, err := transform_member_field("", column.Transform); err != nil {
+			return columns, fmt.Errorf("column %s: %s", column.Column, err.Error())
+		}
+	}
+
+	return columns, nil
+}
+
+func member_column_header(columns []member_column) []string {
+	header := make([]string, len(columns))
+	for i, column := range columns {
+		header[i] = column.Column
+	}
+
+	return header
+}
+
+func member_field(source string, person shared.CopperPerson, customer *stripe.Customer, metadata map[string]string) (string, error) {
+	prefix, name, _ := strings.Cut(source, ".")
+	switch prefix {
+	case "member":
+		switch name {
+		case "prefix":
+			return shared.MemberName(person, customer.Name).Prefix, nil
+		case "first_name":
+			return shared.MemberName(person, customer.Name).First, nil
+		case "middle_name":
+			return shared.MemberName(person, customer.Name).Middle, nil
+		case "last_name":
+			return shared.MemberName(person, customer.Name).Last, nil
+		case "suffix":
+			return shared.MemberName(person, customer.Name).Suffix, nil
+		case "emails":
+			return get_member_emails(person, customer, metadata), nil
+		case "primary_email":
+			return shared.ResolveMemberEmails(person, customer.Email, metadata["owasp_email"]).Primary, nil
+		case "secondary_emails":
+			return strings.Join(shared.ResolveMemberEmails(person, customer.Email, metadata["owasp_email"]).Secondary, "\n"), nil
+		case "invalid_emails":
+			return strings.Join(shared.ResolveMemberEmails(person, customer.Email, metadata["owasp_email"]).Invalid, "\n"), nil
+		case "phone_numbers":
+			return copper_field_value("phone_numbers", person)
+		}
+	case "stripe":
+		switch name {
+		case "id":
+			return customer.ID, nil
+		case "email":
+			return customer.Email, nil
+		case "name":
+			return customer.Name, nil
+		case "phone":
+			return customer.Phone, nil
+		case "country", "postal_code":
+			if customer.Address == nil {
+				return "", nil
+			}
+			if name == "country" {
+				return customer.Address.Country, nil
+			}
+			return customer.Address.PostalCode, nil
+		}
+	case "metadata":
+		if name != "" {
+			return metadata[name], nil
+		}
+	case "copper":
+		return copper_field_value(name, person)
+	case "address":
+		return address_field_value(name, person, customer)
+	case "phone":
+		return phone_field_value(name, person, customer)
+	}
+
+	return "", fmt.Errorf("unknown source %q", source)
+}
+
+func member_address(person shared.CopperPerson, customer *stripe.Customer) shared.NormalizedAddress {
+	fallback_country := ""
+	if customer.Address != nil {
+		fallback_country = customer.Address.Country
+	}
+
+	return shared.NormalizeAddress(person.Address, fallback_country)
+}
+
+func phone_field_value(name string, person shared.CopperPerson, customer *stripe.Customer) (string, error) {
+	if name != "e164" && name != "categories" && name != "issues" {
+		return "", fmt.Errorf("unknown phone field %q", name)
+	}
+
+	values := make([]string, 0, len(person.PhoneNumbers))
+	for _, phone := range shared.PersonPhoneNumbers(person, member_address(person, customer).CountryCode) {
+		switch {
+		case name == "e164" && phone.E164 != "":
+			values = append(values, phone.E164)
+		case name == "e164":
+			values = append(values, phone.Raw)
+		case name == "categories":
+			values = append(values, phone.Category)
+		case name == "issues" && phone.Issue != "":
+			values = append(values, phone.Raw+": "+phone.Issue)
+		}
+	}
+
+	return strings.Join(values, "\n"), nil
+}
+
+func address_field_value(name string, person shared.CopperPerson, customer *stripe.Customer) (string, error) {
+	address := member_address(person, customer)
+
+	switch name {
+	case "street":
+		return address.Street, nil
+	case "city":
+		return address.City, nil
+	case "state":
+		return address.State, nil
+	case "state_code":
+		if address.StateCode == "" {
+			return address.State, nil
+		}
+		return address.StateCode, nil
+	case "country":
+		return address.Country, nil
+	case "country_code":
+		return address.CountryCode, nil
+	case "postal_code":
+		return address.PostalCode, nil
+	case "issues":
+		return strings.Join(address.Issues, "\n"), nil
+	}
+
+	return "", fmt.Errorf("unknown address field %q", name)
+}
+
+var normalized_address_sources = map[string]string{
+	"copper.street":      "address.street",
+	"copper.city":        "address.city",
+	"copper.state":       "address.state_code",
+	"copper.country":     "address.country",
+	"copper.postal_code": "address.postal_code",
+}
+
+// address_columns applies the export's -address mode: raw leaves the columns alone, normalized reads
+// the Copper address columns from the normalized address, and both adds normalized columns after them
+func address_columns(columns []member_column, mode string) ([]member_column, error) {
+	switch mode {
+	case "raw":
+		return columns, nil
+	case "normalized", "both":
+	default:
+		return columns, fmt.Errorf("unknown address mode %q, expected raw, normalized or both", mode)
+	}
+
+	updated := make([]member_column, 0, len(columns)+len(normalized_address_sources)+2)
+	added := make([]member_column, 0)
+	for _, column := range columns {
+		source, ok := normalized_address_sources[column.Source]
+		if ok && mode == "normalized" {
+			column.Source = source
+		} else if ok {
+			added = append(added, member_column{Column: "normalized_" + column.Column, Source: source, Transform: column.Transform})
+		}
+		updated = append(updated, column)
+	}
+	if mode == "both" {
+		added = append(added, member_column{Column: "country_code", Source: "address.country_code"})
+	}
+	updated = append(updated, added...)
+
+	return append(updated, member_column{Column: "address_issues", Source: "address.issues"}), nil
+}
+
+var e164_phone_sources = map[string]bool{
+	"member.phone_numbers": true,
+	"copper.phone_numbers": true,
+}
+
+// phone_columns applies the export's -phones mode: raw leaves the columns alone, e164 reads the phone
+// number columns in E.164, and both adds E.164 columns after them. Either of the last two adds the
+// phone_categories and phone_issues columns.
+func phone_columns(columns []member_column, mode string) ([]member_column, error) {
+	switch mode {
+	case "raw":
+		return columns, nil
+	case "e164", "both":
+	default:
+		return columns, fmt.Errorf("unknown phones mode %q, expected raw, e164 or both", mode)
+	}
+
+	updated := make([]member_column, 0, len(columns)+3)
+	added := make([]member_column, 0)
+	for _, column := range columns {
+		if e164_phone_sources[column.Source] && mode == "e164" {
+			column.Source = "phone.e164"
+		} else if e164_phone_sources[column.Source] {
+			added = append(added, member_column{Column: "e164_" + column.Column, Source: "phone.e164", Transform: column.Transform})
+		}
+		updated = append(updated, column)
+	}
+	updated = append(updated, added...)
+
+	return append(updated, member_column{Column: "phone_categories", Source: "phone.categories"}, member_column{Column: "phone_issues", Source: "phone.issues"}), nil
+}
+
+func copper_field_value(name string, person shared.CopperPerson) (string, error) {
+	switch name {
+	case "first_name":
+		return person.FirstName, nil
+	case "last_name":
+		return person.LastName, nil
+	case "name":
+		return person.Name, nil
+	case "emails":
+		emails := make([]string, len(person.Emails))
+		for i, email := range person.Emails {
+			emails[i] = email.Email
+		}
+		return strings.Join(emails, "\n"), nil
+	case "phone_numbers":
+		phones := make([]string, len(person.PhoneNumbers))
+		for i, phone := range person.PhoneNumbers {
+			phones[i] = phone.Number
+		}
+		return strings.Join(phones, "\n"), nil
+	case "street":
+		return person.Address.Street, nil
+	case "city":
+		return person.Address.City, nil
+	case "state":
+		return person.Address.State, nil
+	case "country":
+		return person.Address.Country, nil
+	case "postal_code":
+		return person.Address.PostalCode, nil
+	case "tags":
+		return strings.Join(person.Tags, "\n"), nil
+	}
+
+	field_id, ok := copper_custom_field_names[name]
+	if !ok && strings.HasPrefix(name, "custom.") {
+		var err error
+		field_id, err = strconv.Atoi(strings.TrimPrefix(name, "custom."))
+		ok = err == nil
+	}
+	if !ok {
+		return "", fmt.Errorf("unknown Copper field %q", name)
+	}
+
+	return shared.CopperStringValue(shared.CopperGetCustomFieldValue(person.CustomFields, field_id)), nil
+}
+
+func transform_member_field(value string, transforms string) (string, error) {
+	if strings.TrimSpace(transforms) == "" {
+		return value, nil
+	}
+	for _, transform := range strings.Split(transforms, "|") {
+		name, arg, _ := strings.Cut(transform, ":")
+		switch strings.TrimSpace(name) {
+		case "trim":
+			value = strings.TrimSpace(value)
+		case "upper":
+			value = strings.ToUpper(value)
+		case "lower":
+			value = strings.ToLower(value)
+		case "title":
+			// each value of a multi value field on its own
+			lines := strings.Split(value, "\n")
+			for i, line := range lines {
+				lines[i] = shared.TitleCase(strings.ToLower(line))
+			}
+			value = strings.Join(lines, "\n")
+		case "date":
+			if arg == "" {
+				return value, fmt.Errorf("date transform needs a layout")
+			}
+			if t, err := shared.StringToDateTimeHelper(value); err == nil {
+				value = t.Format(arg)
+			}
+		case "join":
+			value = strings.ReplaceAll(value, "\n", arg)
+		case "default":
+			if strings.TrimSpace(value) == "" {
+				value = arg
+			}
+		default:
+			return value, fmt.Errorf("unknown transform %q", transform)
+		}
+	}
+
+	return value, nil
+}
+
+func fill_member_row_data(row []string, columns []member_column, person shared.CopperPerson, customer *stripe.Customer, metadata map[string]string) []string {
+	for _, column := range columns {
+		value, _ := member_field(column.Source, person, customer, metadata)
+		value, _ = transform_member_field(value, column.Transform)
+		row = append(row, value)
+	}
+
+	return row
+}
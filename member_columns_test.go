@@ -0,0 +1,184 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/owasp-foundation/admin-local-go/shared"
+	"github.com/owasp-foundation/admin-local-go/shared/coppertest"
+	"github.com/stripe/stripe-go/v73"
+)
+
+// TestMain runs the command tests in a scratch directory, so the files the commands read and write,
+// admin-local-kv.txt with test Copper credentials among them, are never the real ones
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "admin_local_test")
+	if err == nil {
+		err = os.WriteFile(filepath.Join(dir, "admin-local-kv.txt"), []byte("COPPER_API_KEY: test\nCOPPER_USER: test@example.com\n"), 0600)
+	}
+	if err == nil {
+		err = os.Chdir(dir)
+	}
+	if err != nil {
+		panic(err)
+	}
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func TestFillMemberRowData(t *testing.T) {
+	coppertest.Start(t, coppertest.TestFixtures())
+	if shared.CP_person_github_username != 395220 {
+		t.Fatalf("fixture uses 395220 for the GitHub username field, CP_person_github_username is %d", shared.CP_person_github_username)
+	}
+
+	metadata := map[string]string{"membership_type": "one", "membership_start": "2023-01-01", "membership_end": "2024-01-01", "membership_recurring": "yes"}
+	tests := []struct {
+		name     string
+		customer *stripe.Customer
+		want     map[string]string
+	}{
+		{
+			name:     "in copper",
+			customer: &stripe.Customer{Email: "JANE@example.com", Name: "Jane Q Example", Metadata: metadata},
+			want: map[string]string{
+				"first_name": "Jane", "last_name": "Example", "emails": "jane.example@owasp.org\njane@example.com",
+				"phone_numbers": "(217) 555-0100\n555-0199", "street_address": "1 Main St", "city": "Springfield", "state": "IL",
+				"country": "United States", "postal_code": "62701", "membership_type": "one", "membership_start": "2023-01-01",
+				"membership_end": "2024-01-01", "membership_recurring": "yes", "github_id": "janeex", "tags": "member\nvolunteer",
+			},
+		},
+		{
+			name:     "not in copper",
+			customer: &stripe.Customer{Email: "jsmith@example.com", Name: "SMITH, JOHN", Metadata: metadata},
+			want: map[string]string{
+				"first_name": "John", "last_name": "Smith", "emails": "jsmith@example.com", "phone_numbers": "",
+				"street_address": "", "country": "", "membership_type": "one", "github_id": "", "tags": "",
+			},
+		},
+	}
+	for _, tt := range tests {
+		person, err := get_copper_person(tt.customer.Email)
+		if err != nil {
+			t.Fatal(err)
+		}
+		row := fill_member_row_data(make([]string, 0), default_member_columns, person, tt.customer, tt.customer.Metadata)
+		header := member_column_header(default_member_columns)
+		if len(row) != len(header) {
+			t.Fatalf("%s: %d values for %d columns", tt.name, len(row), len(header))
+		}
+		for i, column := range header {
+			if want, ok := tt.want[column]; ok && row[i] != want {
+				t.Errorf("%s: %s = %q, want %q", tt.name, column, row[i], want)
+			}
+		}
+	}
+}
+
+func TestTransformMemberField(t *testing.T) {
+	tests := []struct {
+		value      string
+		transforms string
+		want       string
+		err        bool
+	}{
+		{" Jane ", "trim|upper", "JANE", false},
+		{"JOSÉ ÁLVAREZ", "title", "José Álvarez", false},
+		{"élodie  dupont", "title", "Élodie Dupont", false},
+		{"DE KALB", "title", "De Kalb", false},
+		{"McAllen", "title", "Mcallen", false},
+		{"new york\nSÃO PAULO", "title", "New York\nSão Paulo", false},
+		{"", "title", "", false},
+		{"a\nb", "join:; ", "a; b", false},
+		{"", "default:none", "none", false},
+		{"2023-01-31", "date:01/02/2006", "01/31/2023", false},
+		{"x", "date:", "x", true},
+		{"x", "shout", "x", true},
+	}
+	for _, tt := range tests {
+		got, err := transform_member_field(tt.value, tt.transforms)
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("%q %q: got %q, %v, want %q", tt.value, tt.transforms, got, err, tt.want)
+		}
+	}
+}
+
+func TestLoadMemberColumns(t *testing.T) {
+	tests := []struct {
+		body string
+		err  string
+	}{
+		{`[{"column": "email", "source": "member.primary_email", "transform": "lower"}]`, ""},
+		{`[{"column": "x", "source": "copper.custom.12345"}]`, ""},
+		{`[{"column": "x", "source": "member.shoe_size"}]`, "unknown source"},
+		{`[{"source": "member.emails"}]`, "has no name"},
+		{`[{"column": "x", "source": "member.emails", "transform": "rot13"}]`, "unknown transform"},
+	}
+	for _, tt := range tests {
+		filename := filepath.Join(t.TempDir(), "columns.json")
+		if err := os.WriteFile(filename, []byte(tt.body), 0600); err != nil {
+			t.Fatal(err)
+		}
+		_, err := load_member_columns(filename)
+		if tt.err == "" && err != nil {
+			t.Errorf("%s: %v", tt.body, err)
+		} else if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("%s: got error %v, want %q", tt.body, err, tt.err)
+		}
+	}
+}
+
+func TestDefaultMemberColumns(t *testing.T) {
+	// the layout YourMembership imports today, changes need a -columns file or an opt-in flag
+	want := []string{"first_name", "last_name", "emails", "phone_numbers", "street_address", "city", "state", "country", "postal_code",
+		"membership_type", "membership_start", "membership_end", "membership_recurring", "github_id", "tags"}
+	if got := member_column_header(default_member_columns); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("default columns are %v, want %v", got, want)
+	}
+}
+
+func TestPhoneColumns(t *testing.T) {
+	coppertest.Start(t, coppertest.TestFixtures())
+
+	customer := &stripe.Customer{Email: "jane@example.com"}
+	person, err := get_copper_person(customer.Email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		mode string
+		want map[string]string
+		err  bool
+	}{
+		{"raw", map[string]string{"phone_numbers": "(217) 555-0100\n555-0199"}, false},
+		{"e164", map[string]string{"phone_numbers": "+12175550100\n555-0199", "phone_categories": "mobile\nwork", "phone_issues": "555-0199: not a 10 digit North American number"}, false},
+		{"both", map[string]string{"phone_numbers": "(217) 555-0100\n555-0199", "e164_phone_numbers": "+12175550100\n555-0199", "phone_categories": "mobile\nwork"}, false},
+		{"pretty", nil, true},
+	}
+	for _, tt := range tests {
+		columns, err := phone_columns(default_member_columns, tt.mode)
+		if (err != nil) != tt.err {
+			t.Errorf("%s: error %v, want error %v", tt.mode, err, tt.err)
+		}
+		if tt.err {
+			continue
+		}
+		if tt.mode == "raw" && len(columns) != len(default_member_columns) {
+			t.Errorf("raw added columns: %v", member_column_header(columns))
+		}
+		row := fill_member_row_data(make([]string, 0), columns, person, customer, customer.Metadata)
+		values := make(map[string]string)
+		for i, column := range member_column_header(columns) {
+			values[column] = row[i]
+		}
+		for column, want := range tt.want {
+			if values[column] != want {
+				t.Errorf("%s: %s = %q, want %q", tt.mode, column, values[column], want)
+			}
+		}
+	}
+}
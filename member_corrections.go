@@ -0,0 +1,231 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/owasp-foundation/admin-local-go/shared"
+	"github.com/stripe/stripe-go/v73"
+	"github.com/stripe/stripe-go/v73/customer"
+)
+
+// metadata_change sets one metadata key on a customer, an empty Value removes the key
+type metadata_change struct {
+	CustomerID string
+	Field      string
+	Current    string
+	Value      string
+	Reason     string
+}
+
+// metadata_rule rewrites Field on every member customer whose value equals Match, ignoring case
+type metadata_rule struct {
+	Field string `json:"field"`
+	Match string `json:"match"`
+	Set   string `json:"set"`
+}
+
+// read_metadata_changes reads a customer_id,field,value csv, the same format as the undo files
+func read_metadata_changes(filename string) ([]metadata_change, error) {
+	changes := make([]metadata_change, 0)
+	file, err := os.Open(filename)
+	if err != nil {
+		return changes, err
+	}
+	defer file.Close()
+
+	r := csv.NewReader(file)
+	r.FieldsPerRecord = 3
+	header, err := r.Read()
+	if err != nil {
+		return changes, err
+	}
+	if header[0] != "customer_id" || header[1] != "field" || header[2] != "value" {
+		return changes, errors.New("expected a customer_id,field,value header")
+	}
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return changes, err
+		}
+		changes = append(changes, metadata_change{CustomerID: strings.TrimSpace(record[0]), Field: strings.TrimSpace(record[1]), Value: record[2], Reason: filename})
+	}
+
+	return changes, nil
+}
+
+func read_metadata_rules(filename string) ([]metadata_rule, error) {
+	rules := make([]metadata_rule, 0)
+	body, err := os.ReadFile(filename)
+	if err == nil {
+		err = json.Unmarshal(body, &rules)
+	}
+
+	return rules, err
+}
+
+// plan_metadata_changes fills in each change's current value, dropping those that change nothing,
+// and groups the changes by customer
+func plan_metadata_changes(changes []metadata_change, customers map[string]*stripe.Customer) ([]metadata_change, error) {
+	planned := make([]metadata_change, 0, len(changes))
+	for _, change := range changes {
+		current, ok := customers[change.CustomerID]
+		if !ok {
+			var err error
+			current, err = customer.Get(change.CustomerID, nil)
+			if err != nil {
+				return planned, fmt.Errorf("could not get customer %s: %s", change.CustomerID, err.Error())
+			}
+			customers[change.CustomerID] = current
+		}
+		change.Current = current.Metadata[change.Field]
+		if change.Current != change.Value {
+			planned = append(planned, change)
+		}
+	}
+	sort.SliceStable(planned, func(i, j int) bool { return planned[i].CustomerID < planned[j].CustomerID })
+
+	return planned, nil
+}
+
+func write_metadata_plan(filename string, changes []metadata_change, statuses []string) {
+	records := [][]string{
+		{"customer_id", "field", "current", "new", "reason", "status"},
+	}
+	for i, change := range changes {
+		records = append(records, []string{change.CustomerID, change.Field, change.Current, change.Value, change.Reason, statuses[i]})
+	}
+	write_csv_file(filename, records)
+}
+
+// apply_metadata_changes writes the undo file first, then updates customers one at a time,
+// at most rate requests per second, returning the status of each change. Nothing is changed
+// if the undo file cannot be written.
+func apply_metadata_changes(changes []metadata_change, rate int, undo_filename string) ([]string, error) {
+	undo := [][]string{
+		{"customer_id", "field", "value"},
+	}
+	for _, change := range changes {
+		undo = append(undo, []string{change.CustomerID, change.Field, change.Current})
+	}
+	if err := write_csv_file(undo_filename, undo); err != nil {
+		return nil, err
+	}
+
+	if rate <= 0 {
+		rate = 1
+	}
+	ticker := time.NewTicker(time.Second / time.Duration(rate))
+	defer ticker.Stop()
+
+	statuses := make([]string, len(changes))
+	for i := 0; i < len(changes); {
+		// one update per customer, carrying all of their changes
+		params := &stripe.CustomerParams{}
+		j := i
+		for ; j < len(changes) && changes[j].CustomerID == changes[i].CustomerID; j++ {
+			params.AddMetadata(changes[j].Field, changes[j].Value)
+		}
+		<-ticker.C
+		status := "applied"
+		if _, err := customer.Update(changes[i].CustomerID, params); err != nil {
+			fmt.Println("Failed to update " + changes[i].CustomerID + ": " + err.Error())
+			status = "failed"
+		}
+		for ; i < j; i++ {
+			statuses[i] = status
+		}
+	}
+
+	return statuses, nil
+}
+
+// run_metadata_changes applies the planned changes when apply is set, and writes the plan either way
+func run_metadata_changes(planned []metadata_change, apply bool, rate int, timestamp string) {
+	var err error
+	statuses := make([]string, len(planned))
+	if apply {
+		undo_filename := fmt.Sprintf("metadata_undo_%s.csv", timestamp)
+		statuses, err = apply_metadata_changes(planned, rate, undo_filename)
+		if err != nil {
+			fmt.Println("Could not write undo file, nothing was changed: " + err.Error())
+			return
+		}
+		fmt.Println("To restore the previous values run correct_metadata -file " + undo_filename + " -apply")
+	} else {
+		for i := range statuses {
+			statuses[i] = "planned"
+		}
+		fmt.Printf("%d changes planned, nothing was changed (use -apply)\n", len(planned))
+	}
+	write_metadata_plan(fmt.Sprintf("metadata_corrections_%s.csv", timestamp), planned, statuses)
+}
+
+func correct_metadata(args []string) {
+	flags := flag.NewFlagSet("correct_metadata", flag.ExitOnError)
+	changes_file := flags.String("file", "", "csv of customer_id,field,value changes, e.g. an undo file")
+	rules_file := flags.String("rules", "", "json list of {field, match, set} rules applied to every member customer")
+	apply := flags.Bool("apply", false, "update Stripe; without it only the plan is written")
+	rate := flags.Int("rate", 5, "maximum Stripe updates per second")
+	flags.Parse(args)
+
+	if *changes_file == "" && *rules_file == "" {
+		fmt.Println("Give a -file of changes or a -rules file")
+		return
+	}
+	stripe.Key = shared.GetConfigValue("STRIPE_SECRET", "")
+
+	changes := make([]metadata_change, 0)
+	customers := make(map[string]*stripe.Customer)
+	if *changes_file != "" {
+		file_changes, err := read_metadata_changes(*changes_file)
+		if err != nil {
+			fmt.Println("Failed to read " + *changes_file + ": " + err.Error())
+			return
+		}
+		changes = append(changes, file_changes...)
+	}
+	if *rules_file != "" {
+		rules, err := read_metadata_rules(*rules_file)
+		if err != nil {
+			fmt.Println("Failed to read " + *rules_file + ": " + err.Error())
+			return
+		}
+		members, err := list_member_customers()
+		if err != nil {
+			fmt.Println("Failed to list Stripe customers: " + err.Error())
+			return
+		}
+		for _, current := range members {
+			customers[current.ID] = current
+			for _, rule := range rules {
+				value, ok := current.Metadata[rule.Field]
+				if ok && strings.EqualFold(strings.TrimSpace(value), strings.TrimSpace(rule.Match)) {
+					changes = append(changes, metadata_change{CustomerID: current.ID, Field: rule.Field, Value: rule.Set, Reason: *rules_file})
+				}
+			}
+		}
+	}
+
+	planned, err := plan_metadata_changes(changes, customers)
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+	for _, change := range planned {
+		fmt.Printf("%s %s: %q -> %q\n", change.CustomerID, change.Field, change.Current, change.Value)
+	}
+
+	run_metadata_changes(planned, *apply, *rate, strings.ReplaceAll(time.Now().String(), " ", "_"))
+	fmt.Println("Done")
+}
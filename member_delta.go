@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"sort"
+)
+
+const (
+	member_new     = "new"
+	member_changed = "changed"
+	member_expired = "expired"
+)
+
+// member_export_state is the last export's rows by Stripe customer id, so the next
+// export can send YourMembership only what changed
+type member_export_state struct {
+	AsOf    string              `json:"asof"`
+	Columns []string            `json:"columns"`
+	Members map[string][]string `json:"members"`
+}
+
+// load_member_export_state reads the state file; a missing file gives an empty state,
+// making every member new
+func load_member_export_state(filename string) (member_export_state, error) {
+	state := member_export_state{Members: make(map[string][]string)}
+	body, err := os.ReadFile(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	} else if err != nil {
+		return state, err
+	}
+
+	err = json.Unmarshal(body, &state)
+	if state.Members == nil {
+		state.Members = make(map[string][]string)
+	}
+
+	return state, err
+}
+
+func save_member_export_state(filename string, state member_export_state) error {
+	body, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filename, body, 0600)
+}
+
+func same_row(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// member_export_delta returns the rows that are new or changed since previous, in the order of ids,
+// followed by the previous rows of members no longer exported, each with its change type appended
+func member_export_delta(previous member_export_state, ids []string, rows map[string][]string) ([][]string, map[string]int) {
+	delta := make([][]string, 0)
+	counts := make(map[string]int)
+	for _, id := range ids {
+		old, ok := previous.Members[id]
+		change := member_new
+		if ok && same_row(old, rows[id]) {
+			continue
+		} else if ok {
+			change = member_changed
+		}
+		counts[change]++
+		delta = append(delta, append(append([]string{}, rows[id]...), change))
+	}
+
+	expired := make([]string, 0)
+	for id := range previous.Members {
+		if _, ok := rows[id]; !ok {
+			expired = append(expired, id)
+		}
+	}
+	sort.Strings(expired)
+	for _, id := range expired {
+		counts[member_expired]++
+		delta = append(delta, append(append([]string{}, previous.Members[id]...), member_expired))
+	}
+
+	return delta, counts
+}
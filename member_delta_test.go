@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestMemberExportDelta(t *testing.T) {
+	previous := member_export_state{Members: map[string][]string{
+		"cus_same":    {"Jane", "Example", "2025-01-01"},
+		"cus_renewed": {"John", "Sample", "2024-06-30"},
+		"cus_gone_b":  {"Bob", "Gone", "2024-01-31"},
+		"cus_gone_a":  {"Alice", "Gone", "2024-02-29"},
+	}}
+	tests := []struct {
+		name        string
+		previous    member_export_state
+		ids         []string
+		rows        map[string][]string
+		want        []string
+		want_counts map[string]int
+	}{
+		{
+			name:     "new, changed, unchanged and expired",
+			previous: previous,
+			ids:      []string{"cus_new", "cus_same", "cus_renewed"},
+			rows: map[string][]string{
+				"cus_new":     {"Ann", "New", "2025-03-31"},
+				"cus_same":    {"Jane", "Example", "2025-01-01"},
+				"cus_renewed": {"John", "Sample", "2025-06-30"},
+			},
+			want: []string{
+				"Ann|New|2025-03-31|new",
+				"John|Sample|2025-06-30|changed",
+				"Alice|Gone|2024-02-29|expired",
+				"Bob|Gone|2024-01-31|expired",
+			},
+			want_counts: map[string]int{member_new: 1, member_changed: 1, member_expired: 2},
+		},
+		{
+			name:     "nothing changed",
+			previous: member_export_state{Members: map[string][]string{"cus_same": {"Jane", "Example", "2025-01-01"}}},
+			ids:      []string{"cus_same"},
+			rows:     map[string][]string{"cus_same": {"Jane", "Example", "2025-01-01"}},
+			want:     []string{},
+		},
+		// a column added since the last export changes every row
+		{
+			name:        "different columns",
+			previous:    member_export_state{Members: map[string][]string{"cus_same": {"Jane", "Example"}}},
+			ids:         []string{"cus_same"},
+			rows:        map[string][]string{"cus_same": {"Jane", "Example", "2025-01-01"}},
+			want:        []string{"Jane|Example|2025-01-01|changed"},
+			want_counts: map[string]int{member_changed: 1},
+		},
+		{
+			name:        "no previous export",
+			previous:    member_export_state{Members: map[string][]string{}},
+			ids:         []string{"cus_b", "cus_a"},
+			rows:        map[string][]string{"cus_a": {"Alice"}, "cus_b": {"Bob"}},
+			want:        []string{"Bob|new", "Alice|new"},
+			want_counts: map[string]int{member_new: 2},
+		},
+	}
+	for _, tt := range tests {
+		delta, counts := member_export_delta(tt.previous, tt.ids, tt.rows)
+		got := make([]string, 0, len(delta))
+		for _, row := range delta {
+			got = append(got, strings.Join(row, "|"))
+		}
+		if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+		if fmt.Sprint(counts) != fmt.Sprint(tt.want_counts) {
+			t.Errorf("%s: counts %v, want %v", tt.name, counts, tt.want_counts)
+		}
+	}
+	// the rows are copied, so the saved state is not changed by the appended change type
+	if len(previous.Members["cus_gone_a"]) != 3 {
+		t.Errorf("previous row changed: %q", previous.Members["cus_gone_a"])
+	}
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/owasp-foundation/admin-local-go/shared"
+	"github.com/stripe/stripe-go/v73"
+)
+
+// metadata a merge settles by picking the best membership rather than copying across
+var membership_metadata_keys = []string{"membership_type", "membership_start", "membership_end", "membership_recurring"}
+
+type duplicate_candidate struct {
+	customer   *stripe.Customer
+	person     shared.CopperPerson
+	membership shared.Membership
+	matched_on map[string]bool
+}
+
+// union find over customer indexes
+type customer_clusters []int
+
+func (c customer_clusters) find(i int) int {
+	for c[i] != i {
+		c[i] = c[c[i]]
+		i = c[i]
+	}
+
+	return i
+}
+
+func (c customer_clusters) union(i int, j int) {
+	c[c.find(i)] = c.find(j)
+}
+
+// normalized_customer_name ignores titles, suffixes, middle names and "Last, First" order
+func normalized_customer_name(name string) string {
+	parsed := shared.ParsePersonName(name)
+	if parsed.First == "" || parsed.Last == "" { // a single name matches too many people to be useful
+		return ""
+	}
+
+	return strings.ToLower(parsed.First + " " + parsed.Last)
+}
+
+// merge_metadata lists the metadata to copy onto the kept customer, which already has the best
+// membership: anything, such as owasp_email, that only the merged customers have
+func merge_metadata(keep duplicate_candidate, merged []duplicate_candidate) []string {
+	changes := make([]string, 0)
+	for _, other := range merged {
+		keys := make([]string, 0, len(other.customer.Metadata))
+		for key := range other.customer.Metadata {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			value := strings.TrimSpace(other.customer.Metadata[key])
+			is_membership_key := false
+			for _, membership_key := range membership_metadata_keys {
+				is_membership_key = is_membership_key || key == membership_key
+			}
+			if is_membership_key || value == "" || strings.TrimSpace(keep.customer.Metadata[key]) != "" {
+				continue
+			}
+			change := key + "=" + value
+			duplicate := false
+			for _, existing := range changes {
+				duplicate = duplicate || strings.HasPrefix(existing, key+"=")
+			}
+			if !duplicate {
+				changes = append(changes, change)
+			}
+		}
+	}
+
+	return changes
+}
+
+// duplicate_action is keep or merge within a group known to be one person, and review for a customer
+// that only shares a name with the rest of its cluster
+func duplicate_action(i int, keep int, group_size int) string {
+	if group_size == 1 {
+		return "review"
+	} else if i == keep {
+		return "keep"
+	}
+
+	return "merge"
+}
+
+func duplicate_row(cluster int, action string, candidate duplicate_candidate, merged []duplicate_candidate, now time.Time) []string {
+	metadata := ""
+	if action == "keep" {
+		metadata = strings.Join(merge_metadata(candidate, merged), "\n")
+	}
+	matched_on := make([]string, 0)
+	for kind := range candidate.matched_on {
+		matched_on = append(matched_on, kind)
+	}
+	sort.Strings(matched_on)
+	copper_id := ""
+	if candidate.person.ID != 0 {
+		copper_id = strconv.Itoa(candidate.person.ID)
+	}
+
+	return []string{
+		strconv.Itoa(cluster),
+		action,
+		candidate.customer.ID,
+		candidate.customer.Email,
+		candidate.customer.Name,
+		copper_id,
+		strings.Join(matched_on, ","),
+		candidate.membership.Type,
+		shared.FormatMembershipDate(candidate.membership.Start),
+		shared.FormatMembershipDate(candidate.membership.End),
+		strconv.FormatBool(candidate.membership.IsActive(now)),
+		time.Unix(candidate.customer.Created, 0).UTC().Format("2006-01-02"),
+		metadata,
+	}
+}
+
+// plan_duplicate_customers clusters customers sharing an email, Copper person or, with use_names, a name,
+// and returns the plan rows with the number of clusters and of members counted more than once
+func plan_duplicate_customers(candidates []duplicate_candidate, use_names bool, now time.Time) ([][]string, int, int) {
+	clusters := make(customer_clusters, len(candidates))
+	// the same clusters without name matches: only these are the same person for sure
+	same_person := make(customer_clusters, len(candidates))
+	first_seen := make(map[string]int) // match key -> first customer index with it
+	for i, candidate := range candidates {
+		current := candidate.customer
+		clusters[i] = i
+		same_person[i] = i
+
+		keys := map[string]string{
+			"email:" + shared.EmailKey(current.Email):                   "email",
+			"email:" + shared.EmailKey(current.Metadata["owasp_email"]): "email",
+		}
+		if candidate.person.ID != 0 {
+			keys["copper:"+strconv.Itoa(candidate.person.ID)] = "copper"
+		}
+		if name := normalized_customer_name(current.Name); name != "" && use_names {
+			keys["name:"+name] = "name"
+		}
+		for key, kind := range keys {
+			if key == "email:" {
+				continue
+			}
+			if j, ok := first_seen[key]; ok {
+				clusters.union(i, j)
+				if kind != "name" {
+					same_person.union(i, j)
+				}
+				candidates[i].matched_on[kind] = true
+				candidates[j].matched_on[kind] = true
+			} else {
+				first_seen[key] = i
+			}
+		}
+	}
+
+	groups := make(map[int][]int)
+	for i := range candidates {
+		root := clusters.find(i)
+		groups[root] = append(groups[root], i)
+	}
+	roots := make([]int, 0)
+	for root, members := range groups {
+		if len(members) > 1 {
+			roots = append(roots, root)
+		}
+	}
+	sort.Slice(roots, func(i, j int) bool { return groups[roots[i]][0] < groups[roots[j]][0] })
+
+	double_counted := 0
+	records := make([][]string, 0)
+	for cluster, root := range roots {
+		// customers only joined by name may be different people who share it, so each group known to be
+		// one person gets its own kept customer and the rest of the cluster is left for review
+		people := make(map[int][]int)
+		order := make([]int, 0)
+		for _, i := range groups[root] {
+			person := same_person.find(i)
+			if _, ok := people[person]; !ok {
+				order = append(order, person)
+			}
+			people[person] = append(people[person], i)
+		}
+
+		for _, person := range order {
+			members := people[person]
+			keep := members[0]
+			active := 0
+			for _, i := range members {
+				if later_membership(candidates[i].membership, candidates[keep].membership) {
+					keep = i
+				}
+				if candidates[i].membership.IsActive(now) {
+					active++
+				}
+			}
+			if active > 1 {
+				double_counted += active - 1
+			}
+
+			merged := make([]duplicate_candidate, 0, len(members)-1)
+			for _, i := range members {
+				if i != keep {
+					merged = append(merged, candidates[i])
+				}
+			}
+			ordered := append([]int{keep}, members...)
+			for n, i := range ordered {
+				if n > 0 && i == keep {
+					continue
+				}
+				records = append(records, duplicate_row(cluster+1, duplicate_action(i, keep, len(members)), candidates[i], merged, now))
+			}
+		}
+	}
+
+	return records, len(roots), double_counted
+}
+
+func find_duplicate_customers(args []string) {
+	flags := flag.NewFlagSet("find_duplicate_customers", flag.ExitOnError)
+	nocache := flags.Bool("nocache", false, "look up each customer in Copper instead of using the local cache")
+	noname := flags.Bool("noname", false, "do not cluster customers on name alone")
+	flags.Parse(args)
+
+	fmt.Println("Looking for duplicate Stripe customers...")
+
+	if !*nocache {
+		use_copper_cache()
+	}
+	customers, err := list_member_customers()
+	if err != nil {
+		fmt.Println("Failed to list Stripe customers: " + err.Error())
+		return
+	}
+
+	candidates := make([]duplicate_candidate, len(customers))
+	for i, current := range customers {
+		membership, _ := shared.ParseStripeMembership(current.Metadata)
+		person, err := get_copper_person(current.Email)
+		if err != nil {
+			fmt.Println("Copper lookup for " + current.ID + " failed: " + err.Error())
+		}
+		candidates[i] = duplicate_candidate{current, person, membership, make(map[string]bool)}
+	}
+	rows, clusters, double_counted := plan_duplicate_customers(candidates, !*noname, time.Now())
+	records := append([][]string{
+		{"cluster", "action", "customer_id", "email", "name", "copper_id", "matched_on", "membership_type", "membership_start", "membership_end", "active", "created", "merge_metadata"},
+	}, rows...)
+
+	fmt.Printf("%d clusters of duplicate customers, %d members counted more than once\n", clusters, double_counted)
+	write_csv_file(fmt.Sprintf("duplicate_customers_%s.csv", strings.ReplaceAll(time.Now().String(), " ", "_")), records)
+	fmt.Println("Done")
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/owasp-foundation/admin-local-go/shared"
+	"github.com/stripe/stripe-go/v73"
+)
+
+func TestPlanDuplicateCustomers(t *testing.T) {
+	now := time.Now()
+	candidate := func(id string, email string, name string, copper_id int, end time.Time) duplicate_candidate {
+		return duplicate_candidate{
+			customer:   &stripe.Customer{ID: id, Email: email, Name: name, Metadata: map[string]string{}},
+			person:     shared.CopperPerson{ID: copper_id},
+			membership: shared.Membership{Tier: shared.MembershipOneYear, End: end},
+			matched_on: make(map[string]bool),
+		}
+	}
+	current, lapsed := now.AddDate(0, 6, 0), now.AddDate(-1, 0, 0)
+
+	tests := []struct {
+		name      string
+		use_names bool
+		customers []duplicate_candidate
+		want      []string // customer:action in plan order
+	}{
+		{
+			name:      "same email",
+			use_names: true,
+			customers: []duplicate_candidate{candidate("cus_1", "jane@example.com", "Jane Example", 0, lapsed), candidate("cus_2", "JANE@example.com", "J Example", 0, current)},
+			want:      []string{"cus_2:keep", "cus_1:merge"},
+		},
+		{
+			name:      "same copper person",
+			use_names: true,
+			customers: []duplicate_candidate{candidate("cus_1", "jane@example.com", "", 7, current), candidate("cus_2", "jane@work.example", "", 7, lapsed)},
+			want:      []string{"cus_1:keep", "cus_2:merge"},
+		},
+		{
+			name:      "name only",
+			use_names: true,
+			customers: []duplicate_candidate{candidate("cus_1", "john@example.com", "John Smith", 0, current), candidate("cus_2", "jsmith@example.org", "Smith, John", 0, current)},
+			want:      []string{"cus_1:review", "cus_2:review"},
+		},
+		{
+			name:      "email pair joined to another by name",
+			use_names: true,
+			customers: []duplicate_candidate{
+				candidate("cus_1", "john@example.com", "John Smith", 0, lapsed),
+				candidate("cus_2", "jsmith@example.org", "John Smith", 0, current),
+				candidate("cus_3", "john@example.com", "John Smith", 0, current),
+			},
+			want: []string{"cus_3:keep", "cus_1:merge", "cus_2:review"},
+		},
+		{
+			name:      "names off",
+			use_names: false,
+			customers: []duplicate_candidate{candidate("cus_1", "john@example.com", "John Smith", 0, current), candidate("cus_2", "jsmith@example.org", "John Smith", 0, current)},
+			want:      []string{},
+		},
+	}
+	for _, tt := range tests {
+		rows, _, _ := plan_duplicate_customers(tt.customers, tt.use_names, now)
+		got := make([]string, len(rows))
+		for i, row := range rows {
+			got[i] = row[2] + ":" + row[1]
+		}
+		if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
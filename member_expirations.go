@@ -0,0 +1,102 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/owasp-foundation/admin-local-go/shared"
+)
+
+func upcoming_expirations(args []string) {
+	flags := flag.NewFlagSet("upcoming_expirations", flag.ExitOnError)
+	days := flags.Int("days", 30, "report memberships ending within this many days")
+	nocache := flags.Bool("nocache", false, "look up each customer in Copper instead of using the local cache")
+	flags.Parse(args)
+
+	fmt.Printf("Finding memberships expiring in the next %d days...\n", *days)
+
+	if !*nocache {
+		use_copper_cache()
+	}
+	customers, err := list_member_customers()
+	if err != nil {
+		fmt.Println("Failed to list Stripe customers: " + err.Error())
+		return
+	}
+
+	now := time.Now()
+	until := now.AddDate(0, 0, *days)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	type expiring struct {
+		row        []string
+		membership shared.Membership
+	}
+	members := make([]expiring, 0)
+	counts := make(map[string]int)
+
+	for _, current := range customers {
+		membership, _ := shared.ParseStripeMembership(current.Metadata)
+		if !membership.IsActive(now) || membership.IsActive(until) {
+			continue
+		}
+
+		person, err := get_copper_person(current.Email)
+		if err != nil {
+			fmt.Println("Copper lookup for " + current.ID + " failed: " + err.Error())
+		}
+		firstName, lastName := get_member_name(person, current)
+		copper_id := ""
+		if person.ID != 0 {
+			copper_id = strconv.Itoa(person.ID)
+		}
+		days_left := int(membership.End.Sub(today).Hours() / 24)
+		row := []string{
+			strconv.FormatBool(membership.Recurring),
+			membership.Tier.String(),
+			shared.FormatMembershipDate(membership.End),
+			strconv.Itoa(days_left),
+			firstName,
+			lastName,
+			get_member_emails(person, current, current.Metadata),
+			current.ID,
+			copper_id,
+		}
+		members = append(members, expiring{row, membership})
+		counts[fmt.Sprintf("recurring=%v %s", membership.Recurring, membership.Tier)]++
+	}
+
+	// non-recurring first, those are the ones who need a reminder to renew
+	sort.SliceStable(members, func(i, j int) bool {
+		a, b := members[i].membership, members[j].membership
+		if a.Recurring != b.Recurring {
+			return !a.Recurring
+		}
+		if a.Tier != b.Tier {
+			return a.Tier < b.Tier
+		}
+		return a.End.Before(b.End)
+	})
+
+	records := [][]string{
+		{"recurring", "membership_type", "membership_end", "days_left", "first_name", "last_name", "emails", "customer_id", "copper_id"},
+	}
+	for _, member := range members {
+		records = append(records, member.row)
+	}
+
+	groups := make([]string, 0, len(counts))
+	for group := range counts {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+	for _, group := range groups {
+		fmt.Printf("%s: %d\n", group, counts[group])
+	}
+
+	write_csv_file(fmt.Sprintf("expiring_members_%dd_%s.csv", *days, strings.ReplaceAll(time.Now().String(), " ", "_")), records)
+	fmt.Println("Done")
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/owasp-foundation/admin-local-go/shared"
+	"github.com/stripe/stripe-go/v73"
+)
+
+// copper_opted_out reports whether the person has any of the comma separated opt out tags
+func copper_opted_out(person shared.CopperPerson, optout_tags string) bool {
+	for _, optout := range strings.Split(optout_tags, ",") {
+		optout = strings.TrimSpace(optout)
+		if optout == "" {
+			continue
+		}
+		for _, tag := range person.Tags {
+			if strings.EqualFold(strings.TrimSpace(tag), optout) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// lapsed_members lists members whose membership ended within the window and who have not renewed,
+// under this customer or any other Stripe customer with the same email or Copper person
+func lapsed_members(args []string) {
+	flags := flag.NewFlagSet("lapsed_members", flag.ExitOnError)
+	days := flags.Int("days", 180, "include memberships that ended within this many days")
+	nocache := flags.Bool("nocache", false, "look up each customer in Copper instead of using the local cache")
+	flags.Parse(args)
+
+	optout_tags := shared.GetConfigValue("WINBACK_OPTOUT_TAGS", "unsubscribed,do-not-contact")
+	fmt.Printf("Finding members who lapsed in the last %d days...\n", *days)
+
+	if !*nocache {
+		use_copper_cache()
+	}
+	customers, err := list_member_customers()
+	if err != nil {
+		fmt.Println("Failed to list Stripe customers: " + err.Error())
+		return
+	}
+
+	now := time.Now()
+	since := now.AddDate(0, 0, -*days)
+	type lapsed struct {
+		customer   *stripe.Customer
+		person     shared.CopperPerson
+		membership shared.Membership
+	}
+	candidates := make([]lapsed, 0)
+	active_emails := make(map[string]bool)
+	active_people := make(map[int]bool)
+	for _, current := range customers {
+		membership, _ := shared.ParseStripeMembership(current.Metadata)
+		person, err := get_copper_person(current.Email)
+		if err != nil {
+			// without the Copper person the opt out tags cannot be checked
+			fmt.Println("Copper lookup for " + current.ID + " failed, left out: " + err.Error())
+		}
+		if membership.IsActive(now) {
+			active_emails[shared.EmailKey(current.Email)] = true
+			if person.ID != 0 {
+				active_people[person.ID] = true
+			}
+		} else if err == nil && membership.Tier != shared.MembershipUnknown && membership.End.After(since) && membership.End.Before(now) {
+			candidates = append(candidates, lapsed{current, person, membership})
+		}
+	}
+
+	opted_out := 0
+	renewed := 0
+	records := [][]string{
+		{"first_name", "last_name", "emails", "membership_type", "lapsed_on", "days_since_lapse", "country", "customer_id", "copper_id"},
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].membership.End.After(candidates[j].membership.End) })
+	for _, candidate := range candidates {
+		if active_emails[shared.EmailKey(candidate.customer.Email)] || (candidate.person.ID != 0 && active_people[candidate.person.ID]) {
+			renewed++
+			continue
+		}
+		if copper_opted_out(candidate.person, optout_tags) {
+			opted_out++
+			continue
+		}
+
+		firstName, lastName := get_member_name(candidate.person, candidate.customer)
+		copper_id := ""
+		if candidate.person.ID != 0 {
+			copper_id = strconv.Itoa(candidate.person.ID)
+		}
+		records = append(records, []string{
+			firstName,
+			lastName,
+			get_member_emails(candidate.person, candidate.customer, candidate.customer.Metadata),
+			candidate.membership.Tier.String(),
+			shared.FormatMembershipDate(candidate.membership.End),
+			strconv.Itoa(int(now.Sub(candidate.membership.End).Hours() / 24)),
+			candidate.person.Address.Country,
+			candidate.customer.ID,
+			copper_id,
+		})
+	}
+
+	fmt.Printf("%d lapsed members to contact, %d renewed under another customer, %d opted out\n", len(records)-1, renewed, opted_out)
+	write_csv_file(fmt.Sprintf("lapsed_members_%dd_%s.csv", *days, strings.ReplaceAll(time.Now().String(), " ", "_")), records)
+	fmt.Println("Done")
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/owasp-foundation/admin-local-go/shared"
+	"github.com/stripe/stripe-go/v73"
+)
+
+const (
+	lint_error   = "error"
+	lint_warning = "warning"
+	lint_info    = "info"
+)
+
+type lint_finding struct {
+	Check    string
+	Severity string
+	Field    string
+	Value    string
+	Detail   string
+}
+
+// ambiguous_date explains why a date that parsed could have meant something else, "" if it could not
+func ambiguous_date(value string, layout string) string {
+	if strings.HasSuffix(layout, "/06") {
+		return "two digit year"
+	}
+	if strings.Contains(layout, "/") {
+		parts := strings.Split(strings.TrimSpace(value), "/")
+		first, ferr := strconv.Atoi(parts[0])
+		second, serr := strconv.Atoi(parts[1])
+		if ferr == nil && serr == nil && first != second && first <= 12 && second <= 12 {
+			return "month and day could be swapped"
+		}
+	}
+
+	return ""
+}
+
+func lint_date(findings []lint_finding, metadata map[string]string, field string) []lint_finding {
+	value, ok := metadata[field]
+	if !ok || strings.TrimSpace(value) == "" {
+		return findings
+	}
+
+	_, layout, err := shared.StringToDateTimeLayout(value)
+	if err != nil {
+		return append(findings, lint_finding{"unparseable_date", lint_error, field, value, "not in any accepted date format"})
+	}
+	if reason := ambiguous_date(value, layout); reason != "" {
+		findings = append(findings, lint_finding{"ambiguous_date", lint_warning, field, value, reason})
+	}
+
+	return findings
+}
+
+// lint_membership_metadata checks one customer's metadata; subscriptions is nil when not checked
+func lint_membership_metadata(current *stripe.Customer, subscriptions []*stripe.Subscription, now time.Time) []lint_finding {
+	metadata := current.Metadata
+	findings := make([]lint_finding, 0)
+	membership, _ := shared.ParseStripeMembership(metadata)
+
+	if membership.Tier == shared.MembershipUnknown {
+		findings = append(findings, lint_finding{"unknown_membership_type", lint_error, "membership_type", metadata["membership_type"], "matches no membership tier"})
+	}
+
+	findings = lint_date(findings, metadata, "membership_start")
+	findings = lint_date(findings, metadata, "membership_end")
+	if strings.TrimSpace(metadata["membership_end"]) == "" && membership.Tier != shared.MembershipLifetime && membership.Tier != shared.MembershipHonorary && membership.Tier != shared.MembershipUnknown {
+		findings = append(findings, lint_finding{"missing_end_date", lint_error, "membership_end", "", membership.Tier.String() + " membership needs an end date"})
+	}
+	if !membership.Start.IsZero() && !membership.End.IsZero() && membership.End.Before(membership.Start) {
+		findings = append(findings, lint_finding{"end_before_start", lint_error, "membership_end", metadata["membership_end"], "before membership_start " + metadata["membership_start"]})
+	}
+	if membership.Tier == shared.MembershipLifetime && strings.TrimSpace(metadata["membership_end"]) != "" {
+		findings = append(findings, lint_finding{"lifetime_with_end", lint_warning, "membership_end", metadata["membership_end"], "lifetime memberships do not end"})
+	}
+
+	recurring := strings.TrimSpace(strings.ToLower(metadata["membership_recurring"]))
+	if recurring != "" && !shared.ParseMembershipRecurring(recurring) && recurring != "no" && recurring != "n" && recurring != "false" && recurring != "0" && recurring != "off" {
+		findings = append(findings, lint_finding{"unrecognized_recurring", lint_warning, "membership_recurring", metadata["membership_recurring"], "expected yes or no"})
+	}
+	if subscriptions != nil {
+		subscribed := false
+		for _, sub := range subscriptions {
+			subscribed = subscribed || subscription_renews(sub)
+		}
+		if membership.Recurring && !subscribed {
+			findings = append(findings, lint_finding{"recurring_without_subscription", lint_warning, "membership_recurring", metadata["membership_recurring"], "no active subscription renews this membership"})
+		} else if !membership.Recurring && subscribed {
+			findings = append(findings, lint_finding{"subscription_not_recurring", lint_warning, "membership_recurring", metadata["membership_recurring"], "an active subscription renews this membership"})
+		}
+	}
+
+	owasp_email := strings.TrimSpace(metadata["owasp_email"])
+	if owasp_email != "" {
+		if _, ok := shared.NormalizeEmail(owasp_email); !ok {
+			findings = append(findings, lint_finding{"invalid_owasp_email", lint_error, "owasp_email", owasp_email, "not a valid email address"})
+		} else if !shared.IsOwaspEmail(owasp_email) {
+			findings = append(findings, lint_finding{"invalid_owasp_email", lint_warning, "owasp_email", owasp_email, "not an owasp.org address"})
+		}
+	} else if membership.IsActive(now) {
+		findings = append(findings, lint_finding{"missing_owasp_email", lint_info, "owasp_email", "", "active member without an owasp.org address"})
+	}
+
+	return findings
+}
+
+func lint_members(args []string) {
+	flags := flag.NewFlagSet("lint_members", flag.ExitOnError)
+	nosubscriptions := flags.Bool("nosubscriptions", false, "skip comparing membership_recurring with Stripe subscriptions, one request per customer")
+	flags.Parse(args)
+
+	fmt.Println("Checking Stripe membership metadata...")
+
+	customers, err := list_member_customers()
+	if err != nil {
+		fmt.Println("Failed to list Stripe customers: " + err.Error())
+		return
+	}
+
+	now := time.Now()
+	counts := make(map[string]int)
+	records := [][]string{
+		{"customer_id", "email", "check", "severity", "field", "value", "detail"},
+	}
+	for _, current := range customers {
+		var subscriptions []*stripe.Subscription
+		if !*nosubscriptions {
+			subscriptions, err = list_customer_subscriptions(current.ID)
+			if err != nil {
+				fmt.Println("Could not list subscriptions for " + current.ID + ": " + err.Error())
+				subscriptions = nil
+			}
+		}
+		for _, finding := range lint_membership_metadata(current, subscriptions, now) {
+			counts[finding.Check]++
+			records = append(records, []string{current.ID, current.Email, finding.Check, finding.Severity, finding.Field, finding.Value, finding.Detail})
+		}
+	}
+
+	checks := make([]string, 0, len(counts))
+	for check := range counts {
+		checks = append(checks, check)
+	}
+	sort.Strings(checks)
+	for _, check := range checks {
+		fmt.Printf("%s: %d\n", check, counts[check])
+	}
+
+	write_csv_file(fmt.Sprintf("member_lint_%s.csv", strings.ReplaceAll(time.Now().String(), " ", "_")), records)
+	fmt.Println("Done")
+}
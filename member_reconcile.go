@@ -0,0 +1,197 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/owasp-foundation/admin-local-go/shared"
+	"github.com/stripe/stripe-go/v73"
+)
+
+// issues reported by reconcile_members
+const (
+	reconcile_missing_in_copper      = "missing_in_copper"
+	reconcile_missing_in_stripe      = "missing_in_stripe"
+	reconcile_copper_no_membership   = "copper_no_membership"
+	reconcile_tier_mismatch          = "tier_mismatch"
+	reconcile_start_mismatch         = "start_mismatch"
+	reconcile_end_mismatch           = "end_mismatch"
+	reconcile_stripe_number_missing  = "stripe_number_missing"
+	reconcile_stripe_number_conflict = "stripe_number_conflict"
+	reconcile_duplicate_customers    = "duplicate_stripe_customers"
+	reconcile_stripe_parse_error     = "stripe_metadata_error"
+)
+
+// find_copper_person_for_customer matches on the customer's email, then the owasp_email
+// in their metadata, then a Copper person carrying the customer id as their Stripe number
+func find_copper_person_for_customer(cache *shared.CopperPeopleCache, by_stripe_number map[string][]int, current *stripe.Customer) (shared.CopperPerson, bool) {
+	if person, ok := cache.FindPersonByEmail(current.Email); ok {
+		return person, true
+	}
+	if person, ok := cache.FindPersonByEmail(current.Metadata["owasp_email"]); ok {
+		return person, true
+	}
+	if ids := by_stripe_number[current.ID]; len(ids) > 0 {
+		return cache.People[ids[0]], true
+	}
+
+	return shared.CopperPerson{}, false
+}
+
+func copper_people_by_stripe_number(cache *shared.CopperPeopleCache) map[string][]int {
+	by_stripe_number := make(map[string][]int)
+	for id, person := range cache.People {
+		if number := shared.CopperStripeNumber(person); number != "" {
+			by_stripe_number[number] = append(by_stripe_number[number], id)
+		}
+	}
+	for _, ids := range by_stripe_number {
+		sort.Ints(ids)
+	}
+
+	return by_stripe_number
+}
+
+// sort_reconcile_rows keeps the report stable between runs: by issue, then customer, then copper id
+func sort_reconcile_rows(rows [][]string) {
+	sort.SliceStable(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		if a[0] != b[0] {
+			return a[0] < b[0]
+		}
+		if a[1] != b[1] {
+			return a[1] < b[1]
+		}
+		// copper ids compare as numbers, rows without one first
+		a_id, aerr := strconv.Atoi(a[3])
+		b_id, berr := strconv.Atoi(b[3])
+		if aerr != nil || berr != nil {
+			return aerr != nil && berr == nil
+		}
+		return a_id < b_id
+	})
+}
+
+func reconcile_row(issue string, current *stripe.Customer, person shared.CopperPerson, field string, stripe_value string, copper_value string) []string {
+	customer_id := ""
+	customer_email := ""
+	if current != nil {
+		customer_id = current.ID
+		customer_email = current.Email
+	}
+	copper_id := ""
+	if person.ID != 0 {
+		copper_id = strconv.Itoa(person.ID)
+	}
+
+	return []string{issue, customer_id, customer_email, copper_id, person.Name, field, stripe_value, copper_value}
+}
+
+func reconcile_members(args []string) {
+	flags := flag.NewFlagSet("reconcile_members", flag.ExitOnError)
+	all := flags.Bool("all", false, "also report Copper members with no Stripe customer whose Copper membership has lapsed")
+	flags.Parse(args)
+
+	fmt.Println("Reconciling Stripe and Copper memberships...")
+
+	cache, err := load_copper_cache(false)
+	if err != nil {
+		fmt.Println("Could not load Copper cache: " + err.Error())
+		return
+	}
+	customers, err := list_member_customers()
+	if err != nil {
+		fmt.Println("Failed to list Stripe customers: " + err.Error())
+		return
+	}
+
+	now := time.Now()
+	by_stripe_number := copper_people_by_stripe_number(cache)
+	matched := make(map[int][]string) // copper id -> stripe customer ids
+	records := [][]string{
+		{"issue", "customer_id", "customer_email", "copper_id", "copper_name", "field", "stripe_value", "copper_value"},
+	}
+
+	for _, current := range customers {
+		stripe_membership, serr := shared.ParseStripeMembership(current.Metadata)
+		if serr != nil {
+			records = append(records, reconcile_row(reconcile_stripe_parse_error, current, shared.CopperPerson{}, "", serr.Error(), ""))
+		}
+
+		person, ok := find_copper_person_for_customer(cache, by_stripe_number, current)
+		if !ok {
+			records = append(records, reconcile_row(reconcile_missing_in_copper, current, person, "", stripe_membership.Tier.String(), ""))
+			continue
+		}
+		matched[person.ID] = append(matched[person.ID], current.ID)
+
+		copper_membership, ok := shared.MembershipFromCopper(person)
+		if !ok {
+			records = append(records, reconcile_row(reconcile_copper_no_membership, current, person, "membership", stripe_membership.Tier.String(), ""))
+		} else {
+			if copper_membership.Tier != stripe_membership.Tier {
+				records = append(records, reconcile_row(reconcile_tier_mismatch, current, person, "membership", stripe_membership.Tier.String(), copper_membership.Tier.String()))
+			}
+			if !shared.SameMembershipDate(stripe_membership.Start, copper_membership.Start) {
+				records = append(records, reconcile_row(reconcile_start_mismatch, current, person, "membership_start", shared.FormatMembershipDate(stripe_membership.Start), shared.FormatMembershipDate(copper_membership.Start)))
+			}
+			if !shared.SameMembershipDate(stripe_membership.End, copper_membership.End) {
+				records = append(records, reconcile_row(reconcile_end_mismatch, current, person, "membership_end", shared.FormatMembershipDate(stripe_membership.End), shared.FormatMembershipDate(copper_membership.End)))
+			}
+		}
+
+		stripe_number := shared.CopperStripeNumber(person)
+		if stripe_number == "" {
+			records = append(records, reconcile_row(reconcile_stripe_number_missing, current, person, "stripe_number", current.ID, ""))
+		} else if stripe_number != current.ID {
+			records = append(records, reconcile_row(reconcile_stripe_number_conflict, current, person, "stripe_number", current.ID, stripe_number))
+		}
+	}
+
+	// one Copper person matched by several customers, or one Stripe number on several people
+	for id, customer_ids := range matched {
+		if len(customer_ids) > 1 {
+			person := cache.People[id]
+			records = append(records, reconcile_row(reconcile_duplicate_customers, nil, person, "stripe_customers", strings.Join(customer_ids, "\n"), shared.CopperStripeNumber(person)))
+		}
+	}
+	for number, ids := range by_stripe_number {
+		if len(ids) > 1 {
+			for _, id := range ids {
+				records = append(records, reconcile_row(reconcile_stripe_number_conflict, nil, cache.People[id], "stripe_number", "", number))
+			}
+		}
+	}
+
+	for id, person := range cache.People {
+		if len(matched[id]) > 0 {
+			continue
+		}
+		copper_membership, ok := shared.MembershipFromCopper(person)
+		if ok && (*all || copper_membership.IsActive(now)) {
+			records = append(records, reconcile_row(reconcile_missing_in_stripe, nil, person, "membership", "", copper_membership.Tier.String()))
+		}
+	}
+
+	sort_reconcile_rows(records[1:])
+
+	counts := make(map[string]int)
+	for _, row := range records[1:] {
+		counts[row[0]]++
+	}
+	issues := make([]string, 0, len(counts))
+	for issue := range counts {
+		issues = append(issues, issue)
+	}
+	sort.Strings(issues)
+	for _, issue := range issues {
+		fmt.Printf("%s: %d\n", issue, counts[issue])
+	}
+
+	write_csv_file(fmt.Sprintf("member_reconciliation_%s.csv", strings.ReplaceAll(time.Now().String(), " ", "_")), records)
+	fmt.Println("Done")
+}
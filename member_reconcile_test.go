@@ -0,0 +1,36 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSortReconcileRows(t *testing.T) {
+	row := func(issue string, customer_id string, copper_id string) []string {
+		return []string{issue, customer_id, "", copper_id, "", "", "", ""}
+	}
+	rows := [][]string{
+		row("stripe_number_conflict", "", "1000"),
+		row("stripe_number_conflict", "", "999"),
+		row("duplicate_stripe_customers", "", "42"),
+		row("missing_in_copper", "cus_B", ""),
+		row("missing_in_copper", "cus_A", ""),
+		row("stripe_number_conflict", "", ""),
+		row("stripe_number_conflict", "", "20"),
+	}
+	want := []string{
+		"duplicate_stripe_customers  42",
+		"missing_in_copper cus_A ",
+		"missing_in_copper cus_B ",
+		"stripe_number_conflict  ",
+		"stripe_number_conflict  20",
+		"stripe_number_conflict  999",
+		"stripe_number_conflict  1000",
+	}
+	sort_reconcile_rows(rows)
+	for i, r := range rows {
+		if got := strings.Join([]string{r[0], r[1], r[3]}, " "); got != want[i] {
+			t.Errorf("row %d: got %q, want %q", i, got, want[i])
+		}
+	}
+}
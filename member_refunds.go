@@ -0,0 +1,179 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/owasp-foundation/admin-local-go/shared"
+	"github.com/stripe/stripe-go/v73"
+)
+
+// charge_reversed is true when the money for a charge is gone: fully refunded or a lost dispute.
+// It also returns when that happened, as near as Stripe tells us.
+func charge_reversed(ch *stripe.Charge) (bool, time.Time) {
+	if ch.Dispute != nil && ch.Dispute.Status == stripe.DisputeStatusLost {
+		return true, time.Unix(ch.Dispute.Created, 0).UTC()
+	}
+	if charge_refund_status(ch) == "full" {
+		when := time.Unix(ch.Created, 0).UTC()
+		if ch.Refunds != nil {
+			for _, refund := range ch.Refunds.Data {
+				if refund.Created > when.Unix() {
+					when = time.Unix(refund.Created, 0).UTC()
+				}
+			}
+		}
+		return true, when
+	}
+
+	return false, time.Time{}
+}
+
+// charge_needs_review is a partial refund or a dispute that is not settled yet
+func charge_needs_review(ch *stripe.Charge) string {
+	if ch.Dispute != nil && ch.Dispute.Status != stripe.DisputeStatusLost && ch.Dispute.Status != stripe.DisputeStatusWon {
+		return "dispute " + string(ch.Dispute.Status)
+	}
+	if charge_refund_status(ch) == "partial" {
+		return "partially refunded"
+	}
+
+	return ""
+}
+
+// refunded_charges lists the reversed charges check_refunds has already taken off membership_end,
+// so running it again does not shorten the membership twice
+func refunded_charges(metadata map[string]string) map[string]bool {
+	done := make(map[string]bool)
+	for _, id := range strings.Split(metadata["refunded_charges"], ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			done[id] = true
+		}
+	}
+
+	return done
+}
+
+func tier_years(tier shared.MembershipTier) int {
+	if tier == shared.MembershipTwoYear {
+		return 2
+	}
+
+	return 1
+}
+
+// check_refunds flags customers whose membership is still active although the payment for it was
+// refunded or charged back. Each reversed purchase takes its term off membership_end; when that
+// leaves nothing the membership ends on the day the money went back. The charges are recorded in
+// the refunded_charges metadata with the new end, and skipped from then on.
+func check_refunds(args []string) {
+	now := time.Now()
+	flags := flag.NewFlagSet("check_refunds", flag.ExitOnError)
+	from_str := flags.String("from", now.AddDate(-2, 0, 0).Format("2006-01-02"), "first day of charges to check")
+	to_str := flags.String("to", now.Format("2006-01-02"), "last day of charges to check")
+	apply := flags.Bool("apply", false, "update membership_end in Stripe; without it only the plan is written")
+	rate := flags.Int("rate", 5, "maximum Stripe updates per second")
+	flags.Parse(args)
+
+	from, err := shared.StringToDateTimeHelper(*from_str)
+	if err != nil {
+		fmt.Println("Could not parse -from date " + *from_str)
+		return
+	}
+	to, err := shared.StringToDateTimeHelper(*to_str)
+	if err != nil {
+		fmt.Println("Could not parse -to date " + *to_str)
+		return
+	}
+
+	fmt.Printf("Checking membership charges from %s to %s for refunds and disputes...\n", from.Format("2006-01-02"), to.Format("2006-01-02"))
+
+	charges, err := list_membership_charges(from, to.AddDate(0, 0, 1))
+	if err != nil {
+		fmt.Println("Failed to list Stripe charges: " + err.Error())
+		return
+	}
+
+	type reversal struct {
+		customer *stripe.Customer
+		years    int
+		last     time.Time
+		charges  []string
+	}
+	reversals := make(map[string]*reversal)
+	records := [][]string{
+		{"customer_id", "email", "charge_id", "membership_type", "membership_end", "finding", "action"},
+	}
+	for _, mc := range charges {
+		if mc.charge.Customer == nil {
+			continue
+		}
+		current := mc.charge.Customer
+		membership, _ := shared.ParseStripeMembership(current.Metadata)
+		if !membership.IsActive(now) {
+			continue
+		}
+
+		if reversed, when := charge_reversed(mc.charge); reversed {
+			if refunded_charges(current.Metadata)[mc.charge.ID] {
+				continue
+			}
+			r, ok := reversals[current.ID]
+			if !ok {
+				r = &reversal{customer: current}
+				reversals[current.ID] = r
+			}
+			r.years += tier_years(mc.tier)
+			r.charges = append(r.charges, mc.charge.ID)
+			if when.After(r.last) {
+				r.last = when
+			}
+		} else if finding := charge_needs_review(mc.charge); finding != "" {
+			records = append(records, []string{current.ID, current.Email, mc.charge.ID, membership.Type, shared.FormatMembershipDate(membership.End), finding, "review"})
+		}
+	}
+
+	ids := make([]string, 0, len(reversals))
+	for id := range reversals {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	changes := make([]metadata_change, 0)
+	for _, id := range ids {
+		r := reversals[id]
+		membership, _ := shared.ParseStripeMembership(r.customer.Metadata)
+		finding := "refunded or charged back, still active"
+		if membership.Tier == shared.MembershipLifetime || membership.End.IsZero() {
+			records = append(records, []string{id, r.customer.Email, strings.Join(r.charges, "\n"), membership.Type, "", finding, "review"})
+			continue
+		}
+
+		new_end := membership.End.AddDate(-r.years, 0, 0)
+		action := "shorten"
+		if !new_end.After(r.last) || (!membership.Start.IsZero() && !new_end.After(membership.Start)) {
+			new_end = r.last
+			action = "revoke"
+		}
+		done := strings.TrimSpace(r.customer.Metadata["refunded_charges"])
+		if done != "" {
+			done += ","
+		}
+		changes = append(changes, metadata_change{CustomerID: id, Field: "membership_end", Value: new_end.Format("2006-01-02"), Reason: action + " " + strings.Join(r.charges, " ")},
+			metadata_change{CustomerID: id, Field: "refunded_charges", Value: done + strings.Join(r.charges, ","), Reason: action + " " + strings.Join(r.charges, " ")})
+		records = append(records, []string{id, r.customer.Email, strings.Join(r.charges, "\n"), membership.Type, shared.FormatMembershipDate(membership.End), finding, action + " to " + new_end.Format("2006-01-02")})
+	}
+
+	planned, err := plan_metadata_changes(changes, map[string]*stripe.Customer{})
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+
+	timestamp := strings.ReplaceAll(time.Now().String(), " ", "_")
+	write_csv_file(fmt.Sprintf("membership_refunds_%s.csv", timestamp), records)
+	run_metadata_changes(planned, *apply, *rate, timestamp)
+	fmt.Println("Done")
+}
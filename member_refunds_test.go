@@ -0,0 +1,51 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stripe/stripe-go/v73"
+)
+
+func TestRefundedCharges(t *testing.T) {
+	tests := []struct {
+		value string
+		want  []string
+	}{
+		{"", []string{}},
+		{"ch_1", []string{"ch_1"}},
+		{"ch_1, ch_2,", []string{"ch_1", "ch_2"}},
+	}
+	for _, tt := range tests {
+		got := refunded_charges(map[string]string{"refunded_charges": tt.value})
+		if len(got) != len(tt.want) {
+			t.Errorf("%q: got %v, want %v", tt.value, got, tt.want)
+		}
+		for _, id := range tt.want {
+			if !got[id] {
+				t.Errorf("%q: %s missing from %v", tt.value, id, got)
+			}
+		}
+	}
+}
+
+func TestChargeReversed(t *testing.T) {
+	tests := []struct {
+		name   string
+		charge *stripe.Charge
+		want   bool
+		when   int64
+	}{
+		{"paid", &stripe.Charge{Amount: 5000, Created: 100}, false, 0},
+		{"partial refund", &stripe.Charge{Amount: 5000, AmountRefunded: 1000, Created: 100}, false, 0},
+		{"full refund", &stripe.Charge{Amount: 5000, AmountRefunded: 5000, Created: 100,
+			Refunds: &stripe.RefundList{Data: []*stripe.Refund{{Created: 200}, {Created: 150}}}}, true, 200},
+		{"dispute lost", &stripe.Charge{Amount: 5000, Created: 100, Dispute: &stripe.Dispute{Status: stripe.DisputeStatusLost, Created: 300}}, true, 300},
+		{"dispute open", &stripe.Charge{Amount: 5000, Created: 100, Dispute: &stripe.Dispute{Status: stripe.DisputeStatusNeedsResponse, Created: 300}}, false, 0},
+	}
+	for _, tt := range tests {
+		got, when := charge_reversed(tt.charge)
+		if got != tt.want || (got && when.Unix() != tt.when) {
+			t.Errorf("%s: got %v at %d, want %v at %d", tt.name, got, when.Unix(), tt.want, tt.when)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/owasp-foundation/admin-local-go/shared"
+	"github.com/stripe/stripe-go/v73"
+)
+
+func member_region_row(name []string, data shared.MemberData) []string {
+	row := append([]string{}, name...)
+	for _, tier := range shared.AllMembershipTiers {
+		row = append(row, strconv.Itoa(data.ForTier(tier)))
+	}
+
+	return append(row, strconv.Itoa(data.Total()))
+}
+
+// member_regions counts active members per chapter region and per country by tier. The country
+// is the member's normalized Copper address country, else their Stripe billing country.
+func member_regions(args []string) {
+	flags := flag.NewFlagSet("member_regions", flag.ExitOnError)
+	asof_str := flags.String("asof", "", "count the members active on this date instead of today")
+	nocache := flags.Bool("nocache", false, "look up each customer in Copper instead of using the local cache")
+	workers := flags.Int("workers", 4, "Copper lookups run at once")
+	rate := flags.Int("rate", 3, "maximum Copper lookups started per second when not using the cache")
+	flags.Parse(args)
+
+	asof := time.Now()
+	if *asof_str != "" {
+		var err error
+		asof, err = shared.StringToDateTimeHelper(*asof_str)
+		if err != nil {
+			fmt.Println("Could not parse -asof date " + *asof_str)
+			return
+		}
+	}
+	tiers, err := shared.ConfiguredMembershipTiers()
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+
+	fmt.Printf("Counting members per region as of %s...\n", asof.Format("2006-01-02"))
+
+	if !*nocache {
+		use_copper_cache()
+	}
+	customers, err := list_member_customers()
+	if err != nil {
+		fmt.Println("Failed to list Stripe customers: " + err.Error())
+		return
+	}
+	members := make([]*stripe.Customer, 0)
+	memberships := make([]shared.Membership, 0)
+	for _, current := range customers {
+		membership, _ := shared.ParseStripeMembership(current.Metadata)
+		if tiers.Contains(membership.Tier) && membership.ActiveOn(asof) {
+			members = append(members, current)
+			memberships = append(memberships, membership)
+		}
+	}
+
+	lookup_rate := *rate
+	if copper_cache != nil {
+		lookup_rate = 0
+	}
+	people, failed := enrich_member_customers(members, *workers, lookup_rate)
+	if failed > 0 {
+		fmt.Printf("%d Copper lookups failed, those members are counted by their Stripe billing country\n", failed)
+	}
+
+	regions := make(map[shared.ChapterRegion]*shared.MemberData)
+	countries := make(map[string]*shared.MemberData) // by ISO code, "" when unknown
+	country_names := make(map[string]string)
+	for i, current := range members {
+		address := member_address(people[i], current)
+		region := shared.ChapterRegionForCountry(address.CountryCode)
+		if regions[region] == nil {
+			regions[region] = &shared.MemberData{}
+		}
+		regions[region].Count(memberships[i].Tier)
+		if countries[address.CountryCode] == nil {
+			countries[address.CountryCode] = &shared.MemberData{}
+			country_names[address.CountryCode] = address.Country
+		}
+		countries[address.CountryCode].Count(memberships[i].Tier)
+	}
+
+	header := []string{}
+	for _, tier := range shared.AllMembershipTiers {
+		header = append(header, tier.String())
+	}
+	header = append(header, "total")
+
+	region_records := [][]string{append([]string{"region", "copper_region_option"}, header...)}
+	for _, region := range append(shared.AllChapterRegions, shared.ChapterRegionUnknown) {
+		if data, ok := regions[region]; ok {
+			region_records = append(region_records, member_region_row([]string{region.String(), strconv.Itoa(region.CopperOption())}, *data))
+			fmt.Printf("%s: %d members\n", region, data.Total())
+		}
+	}
+
+	codes := make([]string, 0, len(countries))
+	for code := range countries {
+		codes = append(codes, code)
+	}
+	sort.Slice(codes, func(i, j int) bool {
+		a, b := shared.ChapterRegionForCountry(codes[i]).String(), shared.ChapterRegionForCountry(codes[j]).String()
+		if a != b {
+			return a < b
+		}
+		if countries[codes[i]].Total() != countries[codes[j]].Total() {
+			return countries[codes[i]].Total() > countries[codes[j]].Total()
+		}
+		return codes[i] < codes[j]
+	})
+	country_records := [][]string{append([]string{"region", "country_code", "country"}, header...)}
+	for _, code := range codes {
+		name := country_names[code]
+		if code == "" {
+			name = "unknown"
+		}
+		country_records = append(country_records, member_region_row([]string{shared.ChapterRegionForCountry(code).String(), code, name}, *countries[code]))
+	}
+
+	timestamp := strings.ReplaceAll(time.Now().String(), " ", "_")
+	write_csv_file(fmt.Sprintf("member_regions_asof_%s_%s.csv", asof.Format("2006-01-02"), timestamp), region_records)
+	write_csv_file(fmt.Sprintf("member_countries_asof_%s_%s.csv", asof.Format("2006-01-02"), timestamp), country_records)
+	fmt.Println("Done")
+}
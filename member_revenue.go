@@ -0,0 +1,144 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/owasp-foundation/admin-local-go/shared"
+)
+
+type revenue_line struct {
+	Tier         shared.MembershipTier
+	Currency     string
+	RefundStatus string
+	Charges      int
+	Gross        int64
+	Refunded     int64
+}
+
+// currencies Stripe charges in whole units, see https://stripe.com/docs/currencies#zero-decimal
+var zero_decimal_currencies = map[string]bool{
+	"BIF": true, "CLP": true, "DJF": true, "GNF": true, "JPY": true, "KMF": true, "KRW": true, "MGA": true,
+	"PYG": true, "RWF": true, "UGX": true, "VND": true, "VUV": true, "XAF": true, "XOF": true, "XPF": true,
+}
+
+// format_amount shows the Stripe amount, in minor units unless the currency has none, as major units
+func format_amount(amount int64, currency string) string {
+	if zero_decimal_currencies[strings.ToUpper(currency)] {
+		return strconv.FormatInt(amount, 10)
+	}
+
+	return strconv.FormatFloat(float64(amount)/100, 'f', 2, 64)
+}
+
+func membership_revenue(args []string) {
+	now := time.Now()
+	flags := flag.NewFlagSet("membership_revenue", flag.ExitOnError)
+	from_str := flags.String("from", fmt.Sprintf("%d-01-01", now.Year()), "first day of the range")
+	to_str := flags.String("to", now.Format("2006-01-02"), "last day of the range")
+	flags.Parse(args)
+
+	from, err := shared.StringToDateTimeHelper(*from_str)
+	if err != nil {
+		fmt.Println("Could not parse -from date " + *from_str)
+		return
+	}
+	to, err := shared.StringToDateTimeHelper(*to_str)
+	if err != nil {
+		fmt.Println("Could not parse -to date " + *to_str)
+		return
+	}
+
+	fmt.Printf("Summing membership revenue from %s to %s...\n", from.Format("2006-01-02"), to.Format("2006-01-02"))
+
+	charges, err := list_membership_charges(from, to.AddDate(0, 0, 1))
+	if err != nil {
+		fmt.Println("Failed to list Stripe charges: " + err.Error())
+		return
+	}
+
+	lines := make(map[string]*revenue_line)
+	paying := make(map[shared.MembershipTier]map[string]bool)
+	for _, mc := range charges {
+		key := fmt.Sprintf("%d %s %s", mc.tier, mc.charge.Currency, charge_refund_status(mc.charge))
+		line, ok := lines[key]
+		if !ok {
+			line = &revenue_line{Tier: mc.tier, Currency: strings.ToUpper(string(mc.charge.Currency)), RefundStatus: charge_refund_status(mc.charge)}
+			lines[key] = line
+		}
+		line.Charges++
+		line.Gross += mc.charge.Amount
+		line.Refunded += mc.charge.AmountRefunded
+
+		if mc.charge.Customer != nil && charge_refund_status(mc.charge) != "full" {
+			if paying[mc.tier] == nil {
+				paying[mc.tier] = make(map[string]bool)
+			}
+			paying[mc.tier][mc.charge.Customer.ID] = true
+		}
+	}
+
+	keys := make([]string, 0, len(lines))
+	for key := range lines {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := lines[keys[i]], lines[keys[j]]
+		if a.Tier != b.Tier {
+			return a.Tier < b.Tier
+		}
+		if a.Currency != b.Currency {
+			return a.Currency < b.Currency
+		}
+		return a.RefundStatus < b.RefundStatus
+	})
+	records := [][]string{
+		{"membership_type", "currency", "refund_status", "charges", "gross", "refunded", "net"},
+	}
+	for _, key := range keys {
+		line := lines[key]
+		records = append(records, []string{line.Tier.String(), line.Currency, line.RefundStatus, strconv.Itoa(line.Charges),
+			format_amount(line.Gross, line.Currency), format_amount(line.Refunded, line.Currency), format_amount(line.Gross-line.Refunded, line.Currency)})
+	}
+
+	// reconcile with the member export: who paid in the range against who is a member at its end
+	customers, err := list_member_customers()
+	if err != nil {
+		fmt.Println("Failed to list Stripe customers: " + err.Error())
+		return
+	}
+	active := make(map[string]bool)
+	member_data := shared.MemberData{AsOf: to.Format("2006-01-02")}
+	for _, current := range customers {
+		membership, _ := shared.ParseStripeMembership(current.Metadata)
+		if membership.ActiveOn(to) {
+			active[current.ID] = true
+			member_data.Count(membership.Tier)
+		}
+	}
+	reconciliation := [][]string{
+		{"membership_type", "paying_customers", "active_members_at_end", "paying_not_active"},
+	}
+	for _, tier := range append(shared.MembershipTiers{shared.MembershipUnknown}, shared.AllMembershipTiers...) {
+		not_active := 0
+		for customer_id := range paying[tier] {
+			if !active[customer_id] {
+				not_active++
+			}
+		}
+		if len(paying[tier]) == 0 && member_data.ForTier(tier) == 0 {
+			continue
+		}
+		reconciliation = append(reconciliation, []string{tier.String(), strconv.Itoa(len(paying[tier])), strconv.Itoa(member_data.ForTier(tier)), strconv.Itoa(not_active)})
+		fmt.Printf("%s: %d paying customers, %d active members at %s, %d paid but not active\n", tier, len(paying[tier]), member_data.ForTier(tier), member_data.AsOf, not_active)
+	}
+
+	filename := fmt.Sprintf("membership_revenue_%s_%s_%s", from.Format("2006-01-02"), to.Format("2006-01-02"), strings.ReplaceAll(time.Now().String(), " ", "_"))
+	write_csv_file(filename+".csv", records)
+	write_csv_file(filename+"_reconciliation.csv", reconciliation)
+	fmt.Println("Done")
+}
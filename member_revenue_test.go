@@ -0,0 +1,24 @@
+package main
+
+import "testing"
+
+func TestFormatAmount(t *testing.T) {
+	tests := []struct {
+		amount   int64
+		currency string
+		want     string
+	}{
+		{5000, "USD", "50.00"},
+		{5050, "eur", "50.50"},
+		{0, "GBP", "0.00"},
+		{-1999, "USD", "-19.99"},
+		{7000, "JPY", "7000"},
+		{65000, "krw", "65000"},
+		{30000, "CLP", "30000"},
+	}
+	for _, tt := range tests {
+		if got := format_amount(tt.amount, tt.currency); got != tt.want {
+			t.Errorf("%d %s: got %q, want %q", tt.amount, tt.currency, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/owasp-foundation/admin-local-go/shared"
+)
+
+type member_stats_month struct {
+	shared.MemberData
+	Total         int     `json:"total"`
+	Change        int     `json:"change"`
+	ChangePercent float64 `json:"change_percent"`
+}
+
+func parse_month(value string) (time.Time, error) {
+	t, err := time.Parse("2006-01", strings.TrimSpace(value))
+	if err != nil {
+		t, err = shared.StringToDateTimeHelper(value)
+	}
+
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC), err
+}
+
+// month_as_of is the last day of the month, or now for the current month, which is not over
+func month_as_of(month time.Time, now time.Time) time.Time {
+	month_end := month.AddDate(0, 1, -1)
+	if month_end.After(now) {
+		return now
+	}
+
+	return month_end
+}
+
+// member_stats counts active members per tier at the end of each month, or today for the current
+// month; months that have not started are left out. Stripe metadata only
+// holds the latest membership period, so a member who lapsed and later rejoined with a new
+// membership_start is missing from the months before they rejoined.
+func member_stats(args []string) {
+	flags := flag.NewFlagSet("member_stats", flag.ExitOnError)
+	from_str := flags.String("from", time.Now().AddDate(-1, 0, 0).Format("2006-01"), "first month, yyyy-mm")
+	to_str := flags.String("to", time.Now().Format("2006-01"), "last month, yyyy-mm")
+	format := flags.String("format", "csv", "csv or json")
+	flags.Parse(args)
+
+	from, err := parse_month(*from_str)
+	if err != nil {
+		fmt.Println("Could not parse -from month " + *from_str)
+		return
+	}
+	to, err := parse_month(*to_str)
+	if err != nil {
+		fmt.Println("Could not parse -to month " + *to_str)
+		return
+	}
+	if *format != "csv" && *format != "json" {
+		fmt.Println("Unknown -format " + *format)
+		return
+	}
+	tiers, err := shared.ConfiguredMembershipTiers()
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+
+	fmt.Printf("Computing monthly member counts from %s to %s...\n", from.Format("2006-01"), to.Format("2006-01"))
+
+	customers, err := list_member_customers()
+	if err != nil {
+		fmt.Println("Failed to list Stripe customers: " + err.Error())
+		return
+	}
+	memberships := make([]shared.Membership, 0, len(customers))
+	for _, current := range customers {
+		membership, _ := shared.ParseStripeMembership(current.Metadata)
+		if tiers.Contains(membership.Tier) {
+			memberships = append(memberships, membership)
+		}
+	}
+
+	now := time.Now()
+	months := make([]member_stats_month, 0)
+	for month := from; !month.After(to) && !month.After(now); month = month.AddDate(0, 1, 0) {
+		month_end := month_as_of(month, now)
+		stats := member_stats_month{}
+		stats.Month = month.Year()*100 + int(month.Month())
+		stats.AsOf = month_end.Format("2006-01-02")
+		for _, membership := range memberships {
+			if membership.ActiveOn(month_end) {
+				stats.Count(membership.Tier)
+			}
+		}
+		stats.Total = stats.MemberData.Total()
+		if len(months) > 0 {
+			previous := months[len(months)-1].Total
+			stats.Change = stats.Total - previous
+			if previous > 0 {
+				stats.ChangePercent = float64(stats.Change) * 100 / float64(previous)
+			}
+		}
+		months = append(months, stats)
+	}
+
+	filename := fmt.Sprintf("member_stats_%s_%s_%s", from.Format("2006-01"), to.Format("2006-01"), strings.ReplaceAll(time.Now().String(), " ", "_"))
+	if *format == "json" {
+		write_json_file(filename+".json", months)
+	} else {
+		records := [][]string{
+			{"month", "as_of", "one", "two", "lifetime", "complimentary", "student", "honorary", "total", "change", "change_percent"},
+		}
+		for _, stats := range months {
+			records = append(records, []string{
+				fmt.Sprintf("%d-%02d", stats.Month/100, stats.Month%100),
+				stats.AsOf,
+				strconv.Itoa(stats.One),
+				strconv.Itoa(stats.Two),
+				strconv.Itoa(stats.Lifetime),
+				strconv.Itoa(stats.Complimentary),
+				strconv.Itoa(stats.Student),
+				strconv.Itoa(stats.Honorary),
+				strconv.Itoa(stats.Total),
+				strconv.Itoa(stats.Change),
+				strconv.FormatFloat(stats.ChangePercent, 'f', 1, 64),
+			})
+		}
+		write_csv_file(filename+".csv", records)
+	}
+	fmt.Println("Done")
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMonthAsOf(t *testing.T) {
+	now := time.Date(2024, 6, 15, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		month string
+		want  string
+	}{
+		{"2024-01", "2024-01-31"},
+		{"2024-02", "2024-02-29"},
+		{"2024-05", "2024-05-31"},
+		{"2024-06", "2024-06-15"},
+	}
+	for _, tt := range tests {
+		month, err := parse_month(tt.month)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := month_as_of(month, now).Format("2006-01-02"); got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.month, got, tt.want)
+		}
+	}
+}
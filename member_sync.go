@@ -0,0 +1,168 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/owasp-foundation/admin-local-go/shared"
+	"github.com/stripe/stripe-go/v73"
+)
+
+type copper_field_change struct {
+	Field   string
+	FieldID int
+	Current string
+	New     string
+	Value   interface{}
+}
+
+// copper_membership_changes lists the custom fields that differ between the Stripe membership
+// and the Copper person; fields Stripe has no value for are left alone
+func copper_membership_changes(person shared.CopperPerson, membership shared.Membership, customer_id string) []copper_field_change {
+	changes := make([]copper_field_change, 0)
+	copper_membership, _ := shared.MembershipFromCopper(person)
+
+	if option := membership.Tier.CopperOption(); option != 0 && copper_membership.Tier != membership.Tier {
+		changes = append(changes, copper_field_change{"membership", shared.CP_person_membership, copper_membership.Type, membership.Tier.String(), option})
+	}
+	if !membership.Start.IsZero() && !shared.SameMembershipDate(membership.Start, copper_membership.Start) {
+		changes = append(changes, copper_field_change{"membership_start", shared.CP_person_membership_start,
+			shared.FormatMembershipDate(copper_membership.Start), shared.FormatMembershipDate(membership.Start), membership.Start.Unix()})
+	}
+	if !membership.End.IsZero() && !shared.SameMembershipDate(membership.End, copper_membership.End) {
+		changes = append(changes, copper_field_change{"membership_end", shared.CP_person_membership_end,
+			shared.FormatMembershipDate(copper_membership.End), shared.FormatMembershipDate(membership.End), membership.End.Unix()})
+	}
+	if stripe_number := shared.CopperStripeNumber(person); stripe_number != customer_id {
+		changes = append(changes, copper_field_change{"stripe_number", shared.CP_person_stripe_number, stripe_number, customer_id, customer_id})
+	}
+
+	return changes
+}
+
+// later_membership prefers open ended memberships, then the one ending last
+func later_membership(a shared.Membership, b shared.Membership) bool {
+	if a.IsActive(time.Now()) != b.IsActive(time.Now()) {
+		return a.IsActive(time.Now())
+	}
+	if a.Tier == shared.MembershipLifetime || b.Tier == shared.MembershipLifetime {
+		return a.Tier == shared.MembershipLifetime && b.Tier != shared.MembershipLifetime
+	}
+
+	return a.End.After(b.End)
+}
+
+func sync_copper_members(args []string) {
+	flags := flag.NewFlagSet("sync_copper_members", flag.ExitOnError)
+	apply := flags.Bool("apply", false, "update Copper; without it only the plan is written")
+	flags.Parse(args)
+
+	if *apply {
+		fmt.Println("Syncing Stripe memberships into Copper...")
+	} else {
+		fmt.Println("Planning Copper membership sync, nothing will be changed (use -apply)...")
+	}
+
+	cache, err := load_copper_cache(false)
+	if err != nil {
+		fmt.Println("Could not load Copper cache: " + err.Error())
+		return
+	}
+	customers, err := list_member_customers()
+	if err != nil {
+		fmt.Println("Failed to list Stripe customers: " + err.Error())
+		return
+	}
+
+	// a person matched by several customers gets the membership of the customer with the latest one
+	type source struct {
+		customer   *stripe.Customer
+		membership shared.Membership
+	}
+	sources := make(map[int]source)
+	order := make([]int, 0)
+	by_stripe_number := copper_people_by_stripe_number(cache)
+	// customers whose metadata does not parse are left for correct_metadata rather than copied to Copper
+	skipped := make([][]string, 0)
+	for _, current := range customers {
+		membership, merr := shared.ParseStripeMembership(current.Metadata)
+		if membership.Tier == shared.MembershipUnknown && membership.Type == "" {
+			continue
+		}
+		person, ok := find_copper_person_for_customer(cache, by_stripe_number, current)
+		if merr != nil {
+			copper_id := ""
+			if ok {
+				copper_id = strconv.Itoa(person.ID)
+			}
+			skipped = append(skipped, []string{copper_id, person.Name, current.ID, "", "", merr.Error(), "skipped"})
+			continue
+		}
+		if !ok {
+			continue
+		}
+		existing, seen := sources[person.ID]
+		if !seen {
+			order = append(order, person.ID)
+		}
+		if !seen || later_membership(membership, existing.membership) {
+			sources[person.ID] = source{current, membership}
+		}
+	}
+
+	records := [][]string{
+		{"copper_id", "copper_name", "customer_id", "field", "copper_value", "stripe_value", "status"},
+	}
+	updated := 0
+	failed := 0
+	for _, id := range order {
+		person := cache.People[id]
+		src := sources[id]
+		changes := copper_membership_changes(person, src.membership, src.customer.ID)
+		if len(changes) == 0 {
+			continue
+		}
+
+		status := "planned"
+		if *apply {
+			fields := make(shared.CopperCustomFields, len(changes))
+			for i, change := range changes {
+				fields[i].CustomFieldDefinitionID = change.FieldID
+				fields[i].Value = change.Value
+			}
+			result, uerr := shared.CopperUpdatePersonCustomFields(id, fields)
+			if uerr != nil {
+				fmt.Println("Failed to update " + person.Name + ": " + uerr.Error())
+				status = "failed"
+				failed++
+			} else {
+				cache.People[id] = result
+				status = "applied"
+				updated++
+			}
+			time.Sleep(shared.CP_cache_page_delay)
+		}
+		for _, change := range changes {
+			records = append(records, []string{strconv.Itoa(id), person.Name, src.customer.ID, change.Field, change.Current, change.New, status})
+		}
+	}
+
+	records = append(records, skipped...)
+	if len(skipped) > 0 {
+		fmt.Printf("%d customers skipped, their Stripe metadata could not be parsed\n", len(skipped))
+	}
+	if *apply {
+		if err := cache.Save(); err != nil {
+			fmt.Println("Failed to save Copper cache: " + err.Error())
+		}
+		fmt.Printf("%d people updated, %d failed\n", updated, failed)
+	} else {
+		fmt.Printf("%d field changes planned\n", len(records)-1-len(skipped))
+	}
+
+	write_csv_file(fmt.Sprintf("copper_member_sync_%s.csv", strings.ReplaceAll(time.Now().String(), " ", "_")), records)
+	fmt.Println("Done")
+}
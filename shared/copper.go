@@ -3,9 +3,11 @@ package shared
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -18,6 +20,7 @@ var CP_people_fragment = "people/"
 var CP_related_fragment = ":entity/:entity_id/related"
 var CP_custfields_fragment = "custom_field_definitions/"
 var CP_search_fragment = "search"
+var CP_people_page_size = 200
 
 // Custom Field Definition Ids
 var CP_project_type = 399609
@@ -140,6 +143,18 @@ type CopperCustomFields []struct {
 	Value                   interface{} `json:"value"`
 }
 
+type CopperCustomFieldDefinition struct {
+	ID          int      `json:"id"`
+	Name        string   `json:"name"`
+	DataType    string   `json:"data_type"`
+	AvailableOn []string `json:"available_on"`
+	Options     []struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+		Rank int    `json:"rank"`
+	} `json:"options,omitempty"`
+}
+
 type Opportunities []struct {
 	ID                 int                `json:"id"`
 	Name               string             `json:"name"`
@@ -195,6 +210,10 @@ type Opportunity struct {
 }
 
 func PostCopperRequest(url string, jsonStr string) (*http.Response, error) {
+	return SendCopperRequest("POST", url, jsonStr)
+}
+
+func SendCopperRequest(method string, url string, jsonStr string) (*http.Response, error) {
 	client := &http.Client{
 		Timeout: time.Second * 10,
 	}
@@ -206,7 +225,7 @@ func PostCopperRequest(url string, jsonStr string) (*http.Response, error) {
 		log.Printf("Got error %s", err.Error())
 	} else {
 		body := strings.NewReader(jsonStr)
-		req, err = http.NewRequest("POST", url, body)
+		req, err = http.NewRequest(method, url, body)
 		if err != nil {
 			log.Printf("Got error %s", err.Error())
 		} else {
@@ -242,6 +261,12 @@ func CopperFindPersonByEmailObj(searchtext string) (CopperPerson, error) {
 		r, err = PostCopperRequest(urlstr, string(jsonStr))
 		if err == nil {
 			defer r.Body.Close()
+			// not found is an empty person, any other failure is an error
+			if r.StatusCode == http.StatusNotFound {
+				return person, nil
+			} else if r.StatusCode != http.StatusOK {
+				return person, fmt.Errorf("copper lookup of %s failed with status %s", lstxt, r.Status)
+			}
 			var body []byte
 			body, err = io.ReadAll(r.Body)
 
@@ -257,6 +282,42 @@ func CopperFindPersonByEmailObj(searchtext string) (CopperPerson, error) {
 	return person, err
 }
 
+// CopperSearchPeople returns one page of people modified at or after minimum_modified_date (unix seconds),
+// oldest modification first so the caller can resume from the last date it saw
+func CopperSearchPeople(page_number int, minimum_modified_date int) ([]CopperPerson, error) {
+	type funcdata struct {
+		PageSize            int    `json:"page_size"`
+		SortBy              string `json:"sort_by"`
+		SortDirection       string `json:"sort_direction"`
+		PageNumber          int    `json:"page_number"`
+		MinimumModifiedDate int    `json:"minimum_modified_date,omitempty"`
+	}
+	people := []CopperPerson{}
+	var err error = nil
+
+	if page_number == 0 {
+		page_number = 1
+	}
+
+	data := funcdata{CP_people_page_size, "date_modified", "asc", page_number, minimum_modified_date}
+	url := CP_base_url + CP_people_fragment + CP_search_fragment
+	jsonStr, _ := json.Marshal(data)
+
+	var r *http.Response
+	r, err = PostCopperRequest(url, string(jsonStr))
+	if err == nil {
+		defer r.Body.Close()
+		var body []byte
+		body, err = io.ReadAll(r.Body)
+
+		if err == nil {
+			err = json.Unmarshal(body, &people)
+		}
+	}
+
+	return people, err
+}
+
 func CopperListOpportunities(page_number int, pipeline_ids []int, status_ids []int) (Opportunities, error) {
 	type funcdata struct {
 		PageSize    int    `json:"page_size"`
@@ -304,3 +365,54 @@ func CopperGetCustomFieldValue(custom_fields CopperCustomFields, field_id int) i
 
 	return nil
 }
+
+func CopperGetCustomFieldDefinitions() ([]CopperCustomFieldDefinition, error) {
+	defs := []CopperCustomFieldDefinition{}
+	var err error = nil
+
+	url := CP_base_url + CP_custfields_fragment
+	var r *http.Response
+	r, err = SendCopperRequest("GET", url, "")
+	if err == nil {
+		defer r.Body.Close()
+		var body []byte
+		body, err = io.ReadAll(r.Body)
+
+		if err == nil {
+			err = json.Unmarshal(body, &defs)
+		}
+	}
+
+	return defs, err
+}
+
+// CopperUpdatePersonCustomFields sets the given custom fields on a person, leaving the rest alone,
+// and returns the person as Copper has them after the update
+func CopperUpdatePersonCustomFields(person_id int, custom_fields CopperCustomFields) (CopperPerson, error) {
+	type funcdata struct {
+		CustomFields CopperCustomFields `json:"custom_fields"`
+	}
+	person := CopperPerson{}
+	var err error = nil
+
+	data := funcdata{custom_fields}
+	url := CP_base_url + CP_people_fragment + strconv.Itoa(person_id)
+	jsonStr, _ := json.Marshal(data)
+
+	var r *http.Response
+	r, err = SendCopperRequest("PUT", url, string(jsonStr))
+	if err == nil {
+		defer r.Body.Close()
+		if r.StatusCode != http.StatusOK {
+			return person, fmt.Errorf("copper update of person %d failed with status %s", person_id, r.Status)
+		}
+		var body []byte
+		body, err = io.ReadAll(r.Body)
+
+		if err == nil {
+			err = json.Unmarshal(body, &person)
+		}
+	}
+
+	return person, err
+}
@@ -0,0 +1,115 @@
+package shared
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"time"
+)
+
+// pause between search pages so a refresh stays under Copper's 180 requests per minute
+var CP_cache_page_delay = time.Millisecond * 350
+
+// CopperPeopleCache is a local copy of Copper people, indexed by every email they have,
+// so exports do not have to call fetch_by_email once per Stripe customer
+type CopperPeopleCache struct {
+	LastModified int                  `json:"last_modified"`
+	People       map[int]CopperPerson `json:"people"`
+
+	filename string
+	emails   map[string]int
+}
+
+// LoadCopperPeopleCache reads the cache file; a missing file gives an empty cache
+// which the first Refresh fills completely
+func LoadCopperPeopleCache(filename string) (*CopperPeopleCache, error) {
+	cache := &CopperPeopleCache{
+		People:   make(map[int]CopperPerson),
+		filename: filename,
+	}
+
+	body, err := os.ReadFile(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		cache.buildEmailIndex()
+		return cache, nil
+	} else if err != nil {
+		return cache, err
+	}
+
+	err = json.Unmarshal(body, cache)
+	if cache.People == nil {
+		cache.People = make(map[int]CopperPerson)
+	}
+	cache.buildEmailIndex()
+
+	return cache, err
+}
+
+func (c *CopperPeopleCache) buildEmailIndex() {
+	c.emails = make(map[string]int)
+	for id, person := range c.People {
+		for _, email := range person.Emails {
+			lsemail := EmailKey(email.Email)
+			if lsemail != "" {
+				c.emails[lsemail] = id
+			}
+		}
+	}
+}
+
+// Refresh pulls everyone modified since the last refresh; full starts over from an empty
+// cache, which is the only way to drop people deleted in Copper
+func (c *CopperPeopleCache) Refresh(full bool) error {
+	minimum_modified := c.LastModified
+	if full {
+		c.People = make(map[int]CopperPerson)
+		minimum_modified = 0
+	}
+
+	latest := minimum_modified
+	count := 0
+	for page := 1; ; page++ {
+		people, err := CopperSearchPeople(page, minimum_modified)
+		if err != nil {
+			return err
+		}
+		for _, person := range people {
+			c.People[person.ID] = person
+			if person.DateModified > latest {
+				latest = person.DateModified
+			}
+		}
+		count += len(people)
+		if len(people) < CP_people_page_size {
+			break
+		}
+		time.Sleep(CP_cache_page_delay)
+	}
+
+	c.LastModified = latest
+	c.buildEmailIndex()
+	log.Printf("Copper cache refreshed: %d people updated, %d cached", count, len(c.People))
+
+	return nil
+}
+
+// Save writes the cache back to the file it was loaded from
+func (c *CopperPeopleCache) Save() error {
+	body, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(c.filename, body, 0600)
+}
+
+// FindPersonByEmail matches any of a person's emails, ignoring case
+func (c *CopperPeopleCache) FindPersonByEmail(email string) (CopperPerson, bool) {
+	id, ok := c.emails[EmailKey(email)]
+	if !ok {
+		return CopperPerson{}, false
+	}
+
+	return c.People[id], true
+}
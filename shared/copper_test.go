@@ -0,0 +1,236 @@
+package shared_test
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/owasp-foundation/admin-local-go/shared"
+	"github.com/owasp-foundation/admin-local-go/shared/coppertest"
+)
+
+// TestMain gives the Copper client tests a config with test credentials, which every request
+// needs, and turns off the delay between cache refresh pages
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "copper_test")
+	if err == nil {
+		err = os.WriteFile(filepath.Join(dir, "admin-local-kv.txt"), []byte("COPPER_API_KEY: test\nCOPPER_USER: test@example.com\n"), 0600)
+	}
+	if err == nil {
+		err = os.Chdir(dir)
+	}
+	if err != nil {
+		panic(err)
+	}
+	shared.CP_cache_page_delay = 0
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func person_ids(people []shared.CopperPerson) []int {
+	ids := make([]int, len(people))
+	for i, person := range people {
+		ids[i] = person.ID
+	}
+
+	return ids
+}
+
+func same_ids(a []int, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func TestCopperFindPersonByEmailObj(t *testing.T) {
+	coppertest.Start(t, coppertest.TestFixtures())
+
+	tests := []struct {
+		email   string
+		want_id int
+		err     bool
+	}{
+		{"jane@example.com", 1, false},
+		{"JANE@EXAMPLE.COM", 1, false},
+		{"jane.example@owasp.org", 1, false},
+		{"john.sample@example.org", 2, false},
+		{"nobody@example.com", 0, false},
+		{"", 0, true},
+	}
+	for _, tt := range tests {
+		person, err := shared.CopperFindPersonByEmailObj(tt.email)
+		if (err != nil) != tt.err {
+			t.Errorf("%q: error %v, want error %v", tt.email, err, tt.err)
+		}
+		if person.ID != tt.want_id {
+			t.Errorf("%q: got person %d, want %d", tt.email, person.ID, tt.want_id)
+		}
+	}
+}
+
+func TestCopperSearchPeople(t *testing.T) {
+	coppertest.Start(t, coppertest.TestFixtures())
+	page_size := shared.CP_people_page_size
+	shared.CP_people_page_size = 2
+	defer func() { shared.CP_people_page_size = page_size }()
+
+	tests := []struct {
+		page             int
+		minimum_modified int
+		want             []int
+	}{
+		{1, 0, []int{1, 2}},
+		{2, 0, []int{3}},
+		{3, 0, []int{}},
+		{1, 200, []int{2, 3}},
+		{1, 301, []int{}},
+	}
+	for _, tt := range tests {
+		people, err := shared.CopperSearchPeople(tt.page, tt.minimum_modified)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := person_ids(people); !same_ids(got, tt.want) {
+			t.Errorf("page %d since %d: got %v, want %v", tt.page, tt.minimum_modified, got, tt.want)
+		}
+	}
+}
+
+func TestCopperUpdatePersonCustomFields(t *testing.T) {
+	server := coppertest.Start(t, coppertest.TestFixtures())
+
+	tests := []struct {
+		person_id int
+		field_id  int
+		value     float64
+		err       bool
+	}{
+		{1, shared.CP_person_membership_end, 1704067200, false},
+		{2, shared.CP_person_membership, 674396, false},
+		{99, shared.CP_person_membership, 674396, true},
+	}
+	for _, tt := range tests {
+		fields := shared.CopperCustomFields{{CustomFieldDefinitionID: tt.field_id, Value: tt.value}}
+		updated, err := shared.CopperUpdatePersonCustomFields(tt.person_id, fields)
+		if (err != nil) != tt.err {
+			t.Errorf("person %d: error %v, want error %v", tt.person_id, err, tt.err)
+		}
+		if tt.err {
+			continue
+		}
+		if got := shared.CopperGetCustomFieldValue(updated.CustomFields, tt.field_id); got != tt.value {
+			t.Errorf("person %d: returned field %d = %v, want %v", tt.person_id, tt.field_id, got, tt.value)
+		}
+		stored, _ := server.Person(tt.person_id)
+		if got := shared.CopperGetCustomFieldValue(stored.CustomFields, tt.field_id); got != tt.value {
+			t.Errorf("person %d: stored field %d = %v, want %v", tt.person_id, tt.field_id, got, tt.value)
+		}
+	}
+
+	// fields not in the update are left alone
+	stored, _ := server.Person(1)
+	if option, _ := shared.CopperIntValue(shared.CopperGetCustomFieldValue(stored.CustomFields, shared.CP_person_membership)); option != 674395 {
+		t.Errorf("membership type changed to %d", option)
+	}
+}
+
+func TestCopperGetCustomFieldDefinitions(t *testing.T) {
+	coppertest.Start(t, coppertest.TestFixtures())
+
+	defs, err := shared.CopperGetCustomFieldDefinitions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		id      int
+		name    string
+		options int
+	}{
+		{394882, "Membership Type", 2},
+		{394884, "Membership End", 0},
+	}
+	if len(defs) != len(tests) {
+		t.Fatalf("got %d definitions, want %d", len(defs), len(tests))
+	}
+	for i, tt := range tests {
+		if defs[i].ID != tt.id || defs[i].Name != tt.name || len(defs[i].Options) != tt.options {
+			t.Errorf("definition %d: got %d %q with %d options, want %d %q with %d", i, defs[i].ID, defs[i].Name, len(defs[i].Options), tt.id, tt.name, tt.options)
+		}
+	}
+}
+
+func TestCopperPeopleCacheRefresh(t *testing.T) {
+	coppertest.Start(t, coppertest.TestFixtures())
+	page_size := shared.CP_people_page_size
+	shared.CP_people_page_size = 2
+	defer func() { shared.CP_people_page_size = page_size }()
+
+	filename := filepath.Join(t.TempDir(), "cache.json")
+	cache, err := shared.LoadCopperPeopleCache(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.Refresh(false); err != nil {
+		t.Fatal(err)
+	}
+	if len(cache.People) != 3 || cache.LastModified != 300 {
+		t.Fatalf("first refresh cached %d people up to %d, want 3 up to 300", len(cache.People), cache.LastModified)
+	}
+
+	// an update moves the person's date_modified past the last refresh
+	if _, err := shared.CopperUpdatePersonCustomFields(2, shared.CopperCustomFields{{CustomFieldDefinitionID: shared.CP_person_membership, Value: float64(674396)}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.Refresh(false); err != nil {
+		t.Fatal(err)
+	}
+	if cache.LastModified <= 300 {
+		t.Errorf("incremental refresh left last_modified at %d", cache.LastModified)
+	}
+	if option, _ := shared.CopperIntValue(shared.CopperGetCustomFieldValue(cache.People[2].CustomFields, shared.CP_person_membership)); option != 674396 {
+		t.Errorf("incremental refresh did not pick up the update, option %d", option)
+	}
+	if err := cache.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := shared.LoadCopperPeopleCache(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		email   string
+		want_id int
+		found   bool
+	}{
+		{"jane.example@owasp.org", 1, true},
+		{" John.Sample@example.org ", 2, true},
+		{"ana@example.net", 3, true},
+		{"nobody@example.com", 0, false},
+	}
+	for _, tt := range tests {
+		person, ok := reloaded.FindPersonByEmail(tt.email)
+		if ok != tt.found || person.ID != tt.want_id {
+			t.Errorf("%q: got %d, %v, want %d, %v", tt.email, person.ID, ok, tt.want_id, tt.found)
+		}
+	}
+
+	ids := make([]int, 0, len(reloaded.People))
+	for id := range reloaded.People {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	if !same_ids(ids, []int{1, 2, 3}) {
+		t.Errorf("reloaded cache has people %v", ids)
+	}
+}
@@ -0,0 +1,315 @@
+// Package coppertest is an in-memory stand in for the parts of the Copper developer API
+// this tool uses, for tests and for working on the member commands without Copper access.
+// example_fixtures.json shows the fixture format.
+package coppertest
+
+import (
+	_ "embed"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/owasp-foundation/admin-local-go/shared"
+)
+
+const api_path = "/developer_api/v1/"
+
+// Copper's opportunity status ids, as used by the search status_ids filter
+var opportunity_statuses = map[int]string{0: "Open", 1: "Won", 2: "Lost", 3: "Abandoned"}
+
+type Fixtures struct {
+	People                 []shared.CopperPerson                `json:"people"`
+	Opportunities          []shared.Opportunity                 `json:"opportunities"`
+	CustomFieldDefinitions []shared.CopperCustomFieldDefinition `json:"custom_field_definitions"`
+}
+
+// test_fixtures.json is the people and fields the package tests share: a member with every
+// field the exports read, and two plain people with later modification dates
+//
+//go:embed test_fixtures.json
+var testFixturesJSON []byte
+
+// TestFixtures returns a fresh copy of the shared test fixtures, so a test's updates stay its own
+func TestFixtures() Fixtures {
+	fixtures := Fixtures{}
+	if err := json.Unmarshal(testFixturesJSON, &fixtures); err != nil {
+		panic(err)
+	}
+
+	return fixtures
+}
+
+func LoadFixtures(filename string) (Fixtures, error) {
+	fixtures := Fixtures{}
+	body, err := os.ReadFile(filename)
+	if err == nil {
+		err = json.Unmarshal(body, &fixtures)
+	}
+
+	return fixtures, err
+}
+
+// Server answers Copper requests from its fixtures; updates change the fixtures in place
+type Server struct {
+	mu       sync.Mutex
+	fixtures Fixtures
+	requests []string
+}
+
+func NewServer(fixtures Fixtures) *Server {
+	return &Server{fixtures: fixtures}
+}
+
+// Start serves the fake on a local port and points shared.CP_base_url at it until stop is called
+func (s *Server) Start() (stop func()) {
+	ts := httptest.NewServer(s)
+	previous := shared.CP_base_url
+	shared.CP_base_url = ts.URL + api_path
+
+	return func() {
+		shared.CP_base_url = previous
+		ts.Close()
+	}
+}
+
+// Start serves fixtures for the rest of the test, pointing shared.CP_base_url at them
+func Start(t testing.TB, fixtures Fixtures) *Server {
+	t.Helper()
+	server := NewServer(fixtures)
+	t.Cleanup(server.Start())
+
+	return server
+}
+
+// Requests lists "METHOD path" for every request served so far
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string(nil), s.requests...)
+}
+
+// Person returns the current state of a person, including any updates made through the API
+func (s *Server) Person(id int) (shared.CopperPerson, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, person := range s.fixtures.People {
+		if person.ID == id {
+			return person, true
+		}
+	}
+
+	return shared.CopperPerson{}, false
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := r.URL.Path
+	if idx := strings.Index(path, api_path); idx > -1 {
+		path = path[idx+len(api_path):]
+	}
+	s.requests = append(s.requests, r.Method+" "+path)
+
+	if r.Header.Get("X-PW-AccessToken") == "" || r.Header.Get("X-PW-UserEmail") == "" || r.Header.Get("X-PW-Application") != "developer_api" {
+		write_error(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		write_error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	switch {
+	case r.Method == "POST" && path == shared.CP_people_fragment+"fetch_by_email":
+		s.fetch_by_email(w, body)
+	case r.Method == "POST" && path == shared.CP_people_fragment+shared.CP_search_fragment:
+		s.search_people(w, body)
+	case r.Method == "PUT" && strings.HasPrefix(path, shared.CP_people_fragment):
+		s.update_person(w, strings.TrimPrefix(path, shared.CP_people_fragment), body)
+	case r.Method == "POST" && path == shared.CP_opp_fragment+shared.CP_search_fragment:
+		s.search_opportunities(w, body)
+	case r.Method == "GET" && strings.TrimSuffix(path, "/")+"/" == shared.CP_custfields_fragment:
+		write_json(w, s.fixtures.CustomFieldDefinitions)
+	default:
+		write_error(w, http.StatusNotFound, "Resource not found")
+	}
+}
+
+func (s *Server) fetch_by_email(w http.ResponseWriter, body []byte) {
+	var search struct {
+		Email string `json:"email"`
+	}
+	if err := json.Unmarshal(body, &search); err != nil {
+		write_error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	for _, person := range s.fixtures.People {
+		for _, email := range person.Emails {
+			if strings.EqualFold(email.Email, search.Email) {
+				write_json(w, person)
+				return
+			}
+		}
+	}
+	write_error(w, http.StatusNotFound, "Resource not found")
+}
+
+func (s *Server) search_people(w http.ResponseWriter, body []byte) {
+	var search struct {
+		PageSize            int    `json:"page_size"`
+		PageNumber          int    `json:"page_number"`
+		SortBy              string `json:"sort_by"`
+		SortDirection       string `json:"sort_direction"`
+		MinimumModifiedDate int    `json:"minimum_modified_date"`
+	}
+	if err := json.Unmarshal(body, &search); err != nil {
+		write_error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	people := []shared.CopperPerson{}
+	for _, person := range s.fixtures.People {
+		if person.DateModified >= search.MinimumModifiedDate {
+			people = append(people, person)
+		}
+	}
+	sort.SliceStable(people, func(i, j int) bool {
+		less := people[i].Name < people[j].Name
+		if search.SortBy == "date_modified" {
+			less = people[i].DateModified < people[j].DateModified
+		}
+		if search.SortDirection == "desc" {
+			return !less
+		}
+		return less
+	})
+
+	start, end := page_bounds(len(people), search.PageSize, search.PageNumber)
+	write_json(w, people[start:end])
+}
+
+func (s *Server) update_person(w http.ResponseWriter, idstr string, body []byte) {
+	id, err := strconv.Atoi(idstr)
+	if err != nil {
+		write_error(w, http.StatusNotFound, "Resource not found")
+		return
+	}
+	var update struct {
+		CustomFields shared.CopperCustomFields `json:"custom_fields"`
+	}
+	if err := json.Unmarshal(body, &update); err != nil {
+		write_error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	for i := range s.fixtures.People {
+		person := &s.fixtures.People[i]
+		if person.ID != id {
+			continue
+		}
+		for _, field := range update.CustomFields {
+			found := false
+			for j := range person.CustomFields {
+				if person.CustomFields[j].CustomFieldDefinitionID == field.CustomFieldDefinitionID {
+					person.CustomFields[j].Value = field.Value
+					found = true
+				}
+			}
+			if !found {
+				person.CustomFields = append(person.CustomFields, field)
+			}
+		}
+		person.DateModified = int(time.Now().Unix())
+		write_json(w, person)
+		return
+	}
+	write_error(w, http.StatusNotFound, "Resource not found")
+}
+
+func (s *Server) search_opportunities(w http.ResponseWriter, body []byte) {
+	var search struct {
+		PageSize    int   `json:"page_size"`
+		PageNumber  int   `json:"page_number"`
+		StatusIds   []int `json:"status_ids"`
+		PipelineIds []int `json:"pipeline_ids"`
+	}
+	if err := json.Unmarshal(body, &search); err != nil {
+		write_error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	opps := []shared.Opportunity{}
+	for _, opp := range s.fixtures.Opportunities {
+		if len(search.PipelineIds) > 0 && !contains_int(search.PipelineIds, opp.PipelineID) {
+			continue
+		}
+		if len(search.StatusIds) > 0 {
+			matched := false
+			for _, status_id := range search.StatusIds {
+				matched = matched || opportunity_statuses[status_id] == opp.Status
+			}
+			if !matched {
+				continue
+			}
+		}
+		opps = append(opps, opp)
+	}
+	sort.SliceStable(opps, func(i, j int) bool { return opps[i].Name < opps[j].Name })
+
+	start, end := page_bounds(len(opps), search.PageSize, search.PageNumber)
+	write_json(w, opps[start:end])
+}
+
+func page_bounds(count int, page_size int, page_number int) (int, int) {
+	if page_size <= 0 {
+		page_size = 20
+	}
+	if page_number <= 0 {
+		page_number = 1
+	}
+	start := (page_number - 1) * page_size
+	if start > count {
+		start = count
+	}
+	end := start + page_size
+	if end > count {
+		end = count
+	}
+
+	return start, end
+}
+
+func contains_int(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func write_json(w http.ResponseWriter, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(value)
+}
+
+func write_error(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "status": status, "message": message})
+}
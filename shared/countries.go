@@ -0,0 +1,165 @@
+package shared
+
+import (
+	_ "embed"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// countries.json is ISO 3166-1 with common aliases, built from the Debian iso-codes data, plus
+// ISO 3166-2 subdivisions and postal code patterns for the countries most members come from,
+// ITU calling codes and the OWASP chapter region
+//
+//go:embed countries.json
+var countriesJSON []byte
+
+type Subdivision struct {
+	Code    string   `json:"code"`
+	Name    string   `json:"name"`
+	Aliases []string `json:"aliases,omitempty"`
+}
+
+type Country struct {
+	Alpha2        string        `json:"alpha2"`
+	Alpha3        string        `json:"alpha3"`
+	Name          string        `json:"name"`
+	Aliases       []string      `json:"aliases,omitempty"`
+	CallingCode   string        `json:"calling_code,omitempty"`
+	ChapterRegion ChapterRegion `json:"chapter_region,omitempty"`
+	PostalCode    string        `json:"postal_code,omitempty"`
+	Subdivisions  []Subdivision `json:"subdivisions,omitempty"`
+
+	postalCode *regexp.Regexp
+}
+
+var (
+	countriesOnce sync.Once
+	countries     []Country
+	countryIndex  map[string]int
+	callingCodes  map[string]bool
+)
+
+func countryKey(value string) string {
+	value = strings.ReplaceAll(strings.ToLower(value), ".", "")
+	return strings.Join(strings.Fields(value), " ")
+}
+
+func loadCountries() {
+	if err := json.Unmarshal(countriesJSON, &countries); err != nil {
+		panic("shared/countries.json: " + err.Error())
+	}
+
+	// codes and names win over aliases when they collide
+	countryIndex = make(map[string]int)
+	callingCodes = make(map[string]bool)
+	for i := range countries {
+		if countries[i].CallingCode != "" {
+			callingCodes[countries[i].CallingCode] = true
+		}
+		for _, key := range []string{countries[i].Alpha2, countries[i].Alpha3, countries[i].Name} {
+			countryIndex[countryKey(key)] = i
+		}
+		if countries[i].PostalCode != "" {
+			countries[i].postalCode = regexp.MustCompile("^(?:" + countries[i].PostalCode + ")$")
+		}
+	}
+	for i := range countries {
+		for _, alias := range countries[i].Aliases {
+			if _, ok := countryIndex[countryKey(alias)]; !ok {
+				countryIndex[countryKey(alias)] = i
+			}
+		}
+	}
+}
+
+// LookupCountry finds a country by ISO alpha-2 or alpha-3 code, name or common alias, ignoring case
+func LookupCountry(value string) (Country, bool) {
+	countriesOnce.Do(loadCountries)
+	i, ok := countryIndex[countryKey(value)]
+	if !ok {
+		return Country{}, false
+	}
+
+	return countries[i], true
+}
+
+// LookupSubdivision finds a state or province by code, name or alias; only some countries have them
+func (c Country) LookupSubdivision(value string) (Subdivision, bool) {
+	key := countryKey(value)
+	for _, subdivision := range c.Subdivisions {
+		if key == countryKey(subdivision.Code) || key == countryKey(subdivision.Name) {
+			return subdivision, true
+		}
+	}
+	for _, subdivision := range c.Subdivisions {
+		for _, alias := range subdivision.Aliases {
+			if key == countryKey(alias) {
+				return subdivision, true
+			}
+		}
+	}
+
+	return Subdivision{}, false
+}
+
+// ValidPostalCode checks a normalized postal code against the country's format, true when the format is not known
+func (c Country) ValidPostalCode(postal_code string) bool {
+	return c.postalCode == nil || c.postalCode.MatchString(postal_code)
+}
+
+// NormalizedAddress is a Copper address with ISO country and subdivision codes where they could be
+// found, and the problems found on the way
+type NormalizedAddress struct {
+	Street      string
+	City        string
+	State       string
+	StateCode   string
+	Country     string
+	CountryCode string
+	PostalCode  string
+	Issues      []string
+}
+
+// NormalizeAddress cleans up a Copper address; fallback_country, such as the Stripe billing country,
+// is used when Copper has none. Values that cannot be matched are kept as entered.
+func NormalizeAddress(address CopperAddress, fallback_country string) NormalizedAddress {
+	normalized := NormalizedAddress{
+		Street:     strings.TrimSpace(address.Street),
+		City:       TitleCase(address.City),
+		State:      strings.Join(strings.Fields(address.State), " "),
+		Country:    strings.Join(strings.Fields(address.Country), " "),
+		PostalCode: strings.ToUpper(strings.Join(strings.Fields(address.PostalCode), " ")),
+		Issues:     make([]string, 0),
+	}
+	if normalized.Country == "" {
+		normalized.Country = strings.TrimSpace(fallback_country)
+	}
+
+	country, ok := LookupCountry(normalized.Country)
+	if !ok {
+		if normalized.Country == "" {
+			normalized.Issues = append(normalized.Issues, "missing_country")
+		} else {
+			normalized.Issues = append(normalized.Issues, "unknown_country")
+		}
+		return normalized
+	}
+	normalized.Country = country.Name
+	normalized.CountryCode = country.Alpha2
+
+	if normalized.State != "" && len(country.Subdivisions) > 0 {
+		if subdivision, ok := country.LookupSubdivision(normalized.State); ok {
+			normalized.State = subdivision.Name
+			normalized.StateCode = subdivision.Code
+		} else {
+			normalized.Issues = append(normalized.Issues, "unknown_state")
+		}
+	}
+	if normalized.PostalCode != "" && !country.ValidPostalCode(normalized.PostalCode) {
+		normalized.Issues = append(normalized.Issues, "invalid_postal_code")
+	}
+
+	return normalized
+}
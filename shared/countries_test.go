@@ -0,0 +1,92 @@
+package shared_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/owasp-foundation/admin-local-go/shared"
+)
+
+func TestNormalizeAddress(t *testing.T) {
+	tests := []struct {
+		name     string
+		address  shared.CopperAddress
+		fallback string
+		want     shared.NormalizedAddress
+	}{
+		{
+			name:    "us state code",
+			address: shared.CopperAddress{Street: " 1 Main St ", City: "springfield", State: "il", PostalCode: "62701", Country: "USA"},
+			want:    shared.NormalizedAddress{Street: "1 Main St", City: "Springfield", State: "Illinois", StateCode: "IL", Country: "United States", CountryCode: "US", PostalCode: "62701"},
+		},
+		{
+			name:    "state name and zip+4",
+			address: shared.CopperAddress{City: "Austin", State: "Texas", PostalCode: "78701-1234", Country: "U.S.A."},
+			want:    shared.NormalizedAddress{City: "Austin", State: "Texas", StateCode: "TX", Country: "United States", CountryCode: "US", PostalCode: "78701-1234"},
+		},
+		{
+			name:    "country alias and postal case",
+			address: shared.CopperAddress{City: "LONDON", PostalCode: "sw1a  1aa", Country: "England"},
+			want:    shared.NormalizedAddress{City: "London", Country: "United Kingdom", CountryCode: "GB", PostalCode: "SW1A 1AA"},
+		},
+		{
+			name:    "canadian province",
+			address: shared.CopperAddress{City: "Toronto", State: "Ontario", PostalCode: "M5V 2T6", Country: "ca"},
+			want:    shared.NormalizedAddress{City: "Toronto", State: "Ontario", StateCode: "ON", Country: "Canada", CountryCode: "CA", PostalCode: "M5V 2T6"},
+		},
+		{
+			name:    "invalid postal code",
+			address: shared.CopperAddress{City: "Berlin", PostalCode: "1011", Country: "Deutschland"},
+			want:    shared.NormalizedAddress{City: "Berlin", Country: "Germany", CountryCode: "DE", PostalCode: "1011", Issues: []string{"invalid_postal_code"}},
+		},
+		{
+			name:    "unknown state",
+			address: shared.CopperAddress{State: "Atlantis", Country: "United States"},
+			want:    shared.NormalizedAddress{State: "Atlantis", Country: "United States", CountryCode: "US", Issues: []string{"unknown_state"}},
+		},
+		// place names keep every word capitalized, unlike surname particles
+		{
+			name:    "city particles",
+			address: shared.CopperAddress{City: "LA PAZ", Country: "Bolivia"},
+			want:    shared.NormalizedAddress{City: "La Paz", Country: "Bolivia", CountryCode: "BO"},
+		},
+		{
+			name:    "city particles lower case",
+			address: shared.CopperAddress{City: "den haag", Country: "Holland"},
+			want:    shared.NormalizedAddress{City: "Den Haag", Country: "Netherlands", CountryCode: "NL"},
+		},
+		{
+			name:    "mixed case city kept",
+			address: shared.CopperAddress{City: "Le Mans", Country: "France"},
+			want:    shared.NormalizedAddress{City: "Le Mans", Country: "France", CountryCode: "FR"},
+		},
+		{
+			name:     "fallback country",
+			address:  shared.CopperAddress{City: "DEL MAR", State: "CA", PostalCode: "92014"},
+			fallback: "US",
+			want:     shared.NormalizedAddress{City: "Del Mar", State: "California", StateCode: "CA", Country: "United States", CountryCode: "US", PostalCode: "92014"},
+		},
+		{
+			name:     "copper country wins over fallback",
+			address:  shared.CopperAddress{Country: "Brasil"},
+			fallback: "US",
+			want:     shared.NormalizedAddress{Country: "Brazil", CountryCode: "BR"},
+		},
+		{
+			name:    "unknown country",
+			address: shared.CopperAddress{City: "Springfield", Country: "Freedonia"},
+			want:    shared.NormalizedAddress{City: "Springfield", Country: "Freedonia", Issues: []string{"unknown_country"}},
+		},
+		{
+			name: "missing country",
+			want: shared.NormalizedAddress{Issues: []string{"missing_country"}},
+		},
+	}
+	for _, tt := range tests {
+		got := shared.NormalizeAddress(tt.address, tt.fallback)
+		// Issues is never nil, the formatted values compare it with a nil want as empty
+		if fmt.Sprintf("%+v", got) != fmt.Sprintf("%+v", tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package shared
+
+import (
+	"net/mail"
+	"strings"
+)
+
+const OwaspEmailDomain = "owasp.org"
+
+// EmailKey is the form emails are compared in: trimmed and lower case
+func EmailKey(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// NormalizeEmail trims an address and lower cases its domain, returning false when it is
+// not a single bare address with a dotted domain
+func NormalizeEmail(email string) (string, bool) {
+	email = strings.TrimSpace(email)
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Address != email || address.Name != "" {
+		return email, false
+	}
+	at := strings.LastIndex(email, "@")
+	domain := strings.ToLower(email[at+1:])
+	if !strings.Contains(domain, ".") || strings.HasPrefix(domain, ".") || strings.HasSuffix(domain, ".") {
+		return email, false
+	}
+
+	return email[:at+1] + domain, true
+}
+
+// IsOwaspEmail is true for an address at owasp.org itself, not at a look-alike such as owasp.org.example.com
+func IsOwaspEmail(email string) bool {
+	return strings.HasSuffix(EmailKey(email), "@"+OwaspEmailDomain)
+}
+
+// MemberEmails is a member's addresses from Copper and Stripe, each once
+type MemberEmails struct {
+	Primary   string
+	Secondary []string
+	Invalid   []string
+}
+
+// All lists the primary address first, then the secondary ones
+func (e MemberEmails) All() []string {
+	if e.Primary == "" {
+		return e.Secondary
+	}
+
+	return append([]string{e.Primary}, e.Secondary...)
+}
+
+// ResolveMemberEmails picks the primary address: an owasp.org address in Copper, else the
+// owasp_email from Stripe metadata, else the Stripe customer email, else the first Copper email.
+// The rest keep the order Copper emails, owasp_email, customer email; addresses are compared
+// ignoring case, and invalid ones are set aside.
+func ResolveMemberEmails(person CopperPerson, customer_email string, owasp_email string) MemberEmails {
+	resolved := MemberEmails{Secondary: make([]string, 0), Invalid: make([]string, 0)}
+	seen := make(map[string]bool)
+	valid := make([]string, 0)
+	add := func(email string) {
+		if strings.TrimSpace(email) == "" || seen[EmailKey(email)] {
+			return
+		}
+		seen[EmailKey(email)] = true
+		if normalized, ok := NormalizeEmail(email); ok {
+			valid = append(valid, normalized)
+		} else {
+			resolved.Invalid = append(resolved.Invalid, strings.TrimSpace(email))
+		}
+	}
+	for _, email := range person.Emails {
+		add(email.Email)
+	}
+	copper := len(valid)
+	add(owasp_email)
+	add(customer_email)
+
+	primary := -1
+	for i := 0; i < copper && primary < 0; i++ {
+		if IsOwaspEmail(valid[i]) {
+			primary = i
+		}
+	}
+	for _, preferred := range []string{owasp_email, customer_email} {
+		for i := 0; i < len(valid) && primary < 0; i++ {
+			if EmailKey(valid[i]) == EmailKey(preferred) {
+				primary = i
+			}
+		}
+	}
+	if primary < 0 && len(valid) > 0 {
+		primary = 0
+	}
+
+	for i, email := range valid {
+		if i == primary {
+			resolved.Primary = email
+		} else {
+			resolved.Secondary = append(resolved.Secondary, email)
+		}
+	}
+
+	return resolved
+}
@@ -0,0 +1,104 @@
+package shared_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/owasp-foundation/admin-local-go/shared"
+)
+
+func copper_person_with_emails(emails ...string) shared.CopperPerson {
+	person := shared.CopperPerson{}
+	for _, email := range emails {
+		person.Emails = append(person.Emails, struct {
+			Email    string `json:"email"`
+			Category string `json:"category"`
+		}{Email: email, Category: "work"})
+	}
+
+	return person
+}
+
+func TestResolveMemberEmails(t *testing.T) {
+	tests := []struct {
+		name           string
+		person         shared.CopperPerson
+		customer_email string
+		owasp_email    string
+		want_primary   string
+		want_secondary []string
+		want_invalid   []string
+	}{
+		{
+			name:           "owasp.org address in copper",
+			person:         copper_person_with_emails("jane@example.com", "jane.example@owasp.org"),
+			customer_email: "jane@example.com",
+			want_primary:   "jane.example@owasp.org",
+			want_secondary: []string{"jane@example.com"},
+		},
+		{
+			name:           "owasp_email metadata when copper has no owasp.org address",
+			person:         copper_person_with_emails("jane@example.com"),
+			customer_email: "jane@work.example.com",
+			owasp_email:    "jane.example@owasp.org",
+			want_primary:   "jane.example@owasp.org",
+			want_secondary: []string{"jane@example.com", "jane@work.example.com"},
+		},
+		{
+			name:           "customer email when there is no owasp.org address",
+			person:         copper_person_with_emails("jane@example.com"),
+			customer_email: "jane@work.example.com",
+			want_primary:   "jane@work.example.com",
+			want_secondary: []string{"jane@example.com"},
+		},
+		{
+			name:           "first copper email when stripe has none",
+			person:         copper_person_with_emails("jane@example.com", "j@example.org"),
+			want_primary:   "jane@example.com",
+			want_secondary: []string{"j@example.org"},
+		},
+		// look-alike domains are not OWASP addresses
+		{
+			name:           "owasp.org look-alike",
+			person:         copper_person_with_emails("jane@owasp.org.example.com"),
+			customer_email: "jane@example.com",
+			want_primary:   "jane@example.com",
+			want_secondary: []string{"jane@owasp.org.example.com"},
+		},
+		{
+			name:           "case-insensitive dedupe",
+			person:         copper_person_with_emails("Jane@Example.com", "JANE.EXAMPLE@OWASP.ORG"),
+			customer_email: "jane@EXAMPLE.COM",
+			owasp_email:    " jane.example@owasp.org ",
+			want_primary:   "JANE.EXAMPLE@owasp.org",
+			want_secondary: []string{"Jane@example.com"},
+		},
+		{
+			name:           "invalid addresses set aside",
+			person:         copper_person_with_emails("not an email", "jane@localhost", "Jane <jane@example.com>"),
+			customer_email: "jane@example.com",
+			want_primary:   "jane@example.com",
+			want_invalid:   []string{"not an email", "jane@localhost", "Jane <jane@example.com>"},
+		},
+		{
+			name:         "only invalid addresses",
+			person:       copper_person_with_emails("jane@"),
+			want_invalid: []string{"jane@"},
+		},
+		{
+			name: "no addresses",
+		},
+	}
+	for _, tt := range tests {
+		got := shared.ResolveMemberEmails(tt.person, tt.customer_email, tt.owasp_email)
+		if got.Primary != tt.want_primary {
+			t.Errorf("%s: primary %q, want %q", tt.name, got.Primary, tt.want_primary)
+		}
+		if strings.Join(got.Secondary, ",") != strings.Join(tt.want_secondary, ",") {
+			t.Errorf("%s: secondary %q, want %q", tt.name, got.Secondary, tt.want_secondary)
+		}
+		if strings.Join(got.Invalid, ",") != strings.Join(tt.want_invalid, ",") {
+			t.Errorf("%s: invalid %q, want %q", tt.name, got.Invalid, tt.want_invalid)
+		}
+	}
+}
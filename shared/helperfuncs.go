@@ -10,58 +10,40 @@ import (
 )
 
 func StringToDateTimeHelper(datestring string) (time.Time, error) {
+	t, _, err := StringToDateTimeLayout(datestring)
+	return t, err
+}
+
+// date layouts StringToDateTimeHelper accepts, in the order they are tried
+var DateTimeHelperLayouts = []string{"01/02/2006", "2006-01-02", "1/2/2006", "2006-1-2", "01/02/06", "1/2/06"}
+
+// StringToDateTimeLayout parses like StringToDateTimeHelper and also returns the layout that matched,
+// "unix" for a timestamp
+func StringToDateTimeLayout(datestring string) (time.Time, string, error) {
 	var err error = nil
 	datestring = strings.Trim(datestring, " ")
 
 	var tdefault time.Time
 
 	if datestring == "" {
-		return tdefault, fmt.Errorf("empty date string")
-	}
-
-	layout := "01/02/2006"
-	t, err := time.Parse(layout, datestring)
-	if err == nil {
-		return t, err
-	}
-
-	layout = "2006-01-02"
-	t, err = time.Parse(layout, datestring)
-	if err == nil {
-		return t, err
-	}
-
-	layout = "1/2/2006"
-	t, err = time.Parse(layout, datestring)
-	if err == nil {
-		return t, err
-	}
-
-	layout = "2006-1-2"
-	t, err = time.Parse(layout, datestring)
-	if err == nil {
-		return t, err
+		return tdefault, "", fmt.Errorf("empty date string")
 	}
 
-	layout = "01/02/06"
-	t, err = time.Parse(layout, datestring)
-	if err == nil {
-		return t, err
-	}
-
-	layout = "1/2/06"
-	t, err = time.Parse(layout, datestring)
-	if err == nil {
-		return t, err
+	var t time.Time
+	for _, layout := range DateTimeHelperLayouts {
+		t, err = time.Parse(layout, datestring)
+		if err == nil {
+			return t, layout, err
+		}
 	}
 
 	ivalue, err := strconv.ParseInt(datestring, 10, 64)
 	if err == nil {
 		t = time.Unix(ivalue, 0)
-		return t, err
+		return t, "unix", err
 	}
 
-	return t, err
+	return t, "", err
 }
 
 func ValidateQuery(md5str map[string]string) error {
@@ -118,24 +100,23 @@ func DataFromBodyString(strbody string) map[string]string {
 	return retmap
 }
 
+// GetConfigValue reads "key: value" from admin-local-kv.txt, giving def when the file or the key is missing
+// or the value is empty. The value is the rest of the line, so it may contain spaces.
 func GetConfigValue(key string, def string) string {
 	config, err := os.Open("admin-local-kv.txt")
-	var value string
+	value := def
 	if err == nil {
 		defer config.Close()
 		scanner := bufio.NewScanner(config)
 		for scanner.Scan() {
-			keyvalue := scanner.Text()
-			if strings.Contains(keyvalue, key) {
-				items, err := fmt.Sscanf(keyvalue, key+": %v", &value)
-				value = strings.TrimSpace(value)
-				if items == 0 || err != nil {
+			keyvalue := strings.TrimSpace(scanner.Text())
+			if strings.HasPrefix(keyvalue, key+":") {
+				value = strings.TrimSpace(keyvalue[len(key)+1:])
+				if value == "" {
 					value = def
 				}
 			}
 		}
-	} else {
-		value = def
 	}
 
 	return value
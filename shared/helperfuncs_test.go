@@ -0,0 +1,41 @@
+package shared_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/owasp-foundation/admin-local-go/shared"
+)
+
+func TestGetConfigValue(t *testing.T) {
+	// TestMain left the Copper credentials in the working directory
+	original, err := os.ReadFile("admin-local-kv.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.WriteFile("admin-local-kv.txt", original, 0600)
+
+	config := string(original) + "WINBACK_OPTOUT_TAGS: do not contact, unsubscribed \nCOPPER_CACHE_FILE:\nYM_COLUMNS_FILE: cols.json\nXYM_COLUMNS_FILE: other.json\n  STRIPE_SECRET: sk_test\n"
+	if err := os.WriteFile("admin-local-kv.txt", []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		key  string
+		def  string
+		want string
+	}{
+		{"WINBACK_OPTOUT_TAGS", "", "do not contact, unsubscribed"},
+		{"COPPER_CACHE_FILE", "copper_people_cache.json", "copper_people_cache.json"},
+		{"COPPER_CACHE_FILE", "", ""},
+		{"YM_COLUMNS_FILE", "", "cols.json"},
+		{"STRIPE_SECRET", "", "sk_test"},
+		{"COPPER_USER", "", "test@example.com"},
+		{"COPPER_BASE_URL", "https://api.copper.com/developer_api/v1/", "https://api.copper.com/developer_api/v1/"},
+	}
+	for _, tt := range tests {
+		if got := shared.GetConfigValue(tt.key, tt.def); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
@@ -1,9 +1,55 @@
 package shared
 
 type MemberData struct {
-	Month         int `json:"month"`
-	One           int `json:"one"`
-	Two           int `json:"two"`
-	Lifetime      int `json:"lifetime"`
-	Complimentary int `json:"complimentary"`
+	AsOf          string `json:"as_of,omitempty"`
+	ExpiryCutoff  string `json:"expiry_cutoff,omitempty"`
+	Month         int    `json:"month"`
+	One           int    `json:"one"`
+	Two           int    `json:"two"`
+	Lifetime      int    `json:"lifetime"`
+	Complimentary int    `json:"complimentary"`
+	Student       int    `json:"student"`
+	Honorary      int    `json:"honorary"`
+}
+
+// Count adds one member of the given tier
+func (d *MemberData) Count(tier MembershipTier) {
+	switch tier {
+	case MembershipOneYear:
+		d.One++
+	case MembershipTwoYear:
+		d.Two++
+	case MembershipLifetime:
+		d.Lifetime++
+	case MembershipComplimentary:
+		d.Complimentary++
+	case MembershipStudent:
+		d.Student++
+	case MembershipHonorary:
+		d.Honorary++
+	}
+}
+
+// ForTier is the count for one tier, 0 for unknown
+func (d MemberData) ForTier(tier MembershipTier) int {
+	switch tier {
+	case MembershipOneYear:
+		return d.One
+	case MembershipTwoYear:
+		return d.Two
+	case MembershipLifetime:
+		return d.Lifetime
+	case MembershipComplimentary:
+		return d.Complimentary
+	case MembershipStudent:
+		return d.Student
+	case MembershipHonorary:
+		return d.Honorary
+	}
+
+	return 0
+}
+
+func (d MemberData) Total() int {
+	return d.One + d.Two + d.Lifetime + d.Complimentary + d.Student + d.Honorary
 }
@@ -0,0 +1,226 @@
+package shared
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+type MembershipTier int
+
+const (
+	MembershipUnknown MembershipTier = iota
+	MembershipOneYear
+	MembershipTwoYear
+	MembershipLifetime
+	MembershipComplimentary
+	MembershipStudent
+	MembershipHonorary
+)
+
+type MembershipTiers []MembershipTier
+
+// every known tier, in the order reports list them
+var AllMembershipTiers = MembershipTiers{MembershipOneYear, MembershipTwoYear, MembershipLifetime, MembershipComplimentary, MembershipStudent, MembershipHonorary}
+
+// where a Membership was read from
+const MembershipSourceStripe = "stripe"
+
+func (t MembershipTier) String() string {
+	switch t {
+	case MembershipOneYear:
+		return "one"
+	case MembershipTwoYear:
+		return "two"
+	case MembershipLifetime:
+		return "lifetime"
+	case MembershipComplimentary:
+		return "complimentary"
+	case MembershipStudent:
+		return "student"
+	case MembershipHonorary:
+		return "honorary"
+	}
+
+	return "unknown"
+}
+
+func (tiers MembershipTiers) Contains(tier MembershipTier) bool {
+	for _, t := range tiers {
+		if t == tier {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ConfiguredMembershipTiers reads the comma separated MEMBERSHIP_TIERS config value,
+// e.g. "one,two,lifetime", defaulting to every known tier
+func ConfiguredMembershipTiers() (MembershipTiers, error) {
+	value := GetConfigValue("MEMBERSHIP_TIERS", "")
+	if strings.TrimSpace(value) == "" {
+		return AllMembershipTiers, nil
+	}
+
+	tiers := MembershipTiers{}
+	for _, name := range strings.Split(value, ",") {
+		tier := ParseMembershipTier(name)
+		if tier == MembershipUnknown {
+			return AllMembershipTiers, fmt.Errorf("MEMBERSHIP_TIERS has unknown tier %q", name)
+		}
+		tiers = append(tiers, tier)
+	}
+
+	return tiers, nil
+}
+
+// words of a membership_type that name a tier; the rest, like "year" or "membership", are ignored
+var membershipTierAliases = map[string]MembershipTier{
+	"one": MembershipOneYear, "oneyear": MembershipOneYear, "1yr": MembershipOneYear, "1year": MembershipOneYear, "1years": MembershipOneYear,
+	"annual": MembershipOneYear,
+	"two":    MembershipTwoYear, "twoyear": MembershipTwoYear, "2yr": MembershipTwoYear, "2year": MembershipTwoYear, "2years": MembershipTwoYear,
+	"lifetime": MembershipLifetime, "life": MembershipLifetime,
+	"complimentary": MembershipComplimentary, "comp": MembershipComplimentary,
+	"student":  MembershipStudent,
+	"honorary": MembershipHonorary,
+}
+
+// these win wherever they appear, e.g. "lifetime (was one year)"; otherwise the first one or two year
+// word decides, as in "two year (upgraded from one)", and complimentary is the last resort
+var membershipTierPrecedence = MembershipTiers{MembershipLifetime, MembershipHonorary, MembershipStudent}
+
+// ParseMembershipTier matches the free text membership_type used in Stripe metadata word by word,
+// e.g. "one", "One Year", "two-year", "1yr", "lifetime", "student"
+func ParseMembershipTier(membership_type string) MembershipTier {
+	words := strings.FieldsFunc(strings.ToLower(membership_type), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	found := make(map[MembershipTier]bool)
+	term := MembershipUnknown
+	for i, word := range words {
+		tier, ok := membershipTierAliases[word]
+		// a bare number is not a tier, but "1 year" and "2 yr" are
+		if !ok && i+1 < len(words) {
+			tier, ok = membershipTierAliases[word+words[i+1]]
+		}
+		if !ok {
+			continue
+		}
+		found[tier] = true
+		if term == MembershipUnknown && (tier == MembershipOneYear || tier == MembershipTwoYear) {
+			term = tier
+		}
+	}
+	for _, tier := range membershipTierPrecedence {
+		if found[tier] {
+			return tier
+		}
+	}
+	if term == MembershipUnknown && found[MembershipComplimentary] {
+		return MembershipComplimentary
+	}
+
+	return term
+}
+
+type Membership struct {
+	Tier       MembershipTier
+	Type       string // membership_type as written in the source
+	Start      time.Time
+	End        time.Time
+	Recurring  bool
+	OwaspEmail string
+	Source     string
+}
+
+// ParseStripeMembership reads the membership_* and owasp_email keys of Stripe customer metadata.
+// Everything that can be parsed is filled in even when an error is returned, the error
+// describes the first problem found.
+func ParseStripeMembership(metadata map[string]string) (Membership, error) {
+	var err error = nil
+	membership := Membership{
+		Type:       strings.TrimSpace(metadata["membership_type"]),
+		Tier:       ParseMembershipTier(metadata["membership_type"]),
+		Recurring:  ParseMembershipRecurring(metadata["membership_recurring"]),
+		OwaspEmail: strings.TrimSpace(metadata["owasp_email"]),
+		Source:     MembershipSourceStripe,
+	}
+
+	if membership.Tier == MembershipUnknown {
+		err = fmt.Errorf("unknown membership_type %q", membership.Type)
+	}
+
+	if strings.TrimSpace(metadata["membership_start"]) != "" {
+		start, serr := StringToDateTimeHelper(metadata["membership_start"])
+		if serr != nil && err == nil {
+			err = fmt.Errorf("membership_start %q could not be parsed", metadata["membership_start"])
+		}
+		membership.Start = start
+	}
+
+	end, eerr := StringToDateTimeHelper(metadata["membership_end"])
+	if eerr == nil {
+		membership.End = end
+	} else if err == nil && membership.Tier != MembershipLifetime {
+		if strings.TrimSpace(metadata["membership_end"]) != "" {
+			err = fmt.Errorf("membership_end %q could not be parsed", metadata["membership_end"])
+		} else if !membership.openEnded() {
+			err = fmt.Errorf("membership_end is missing")
+		}
+	}
+
+	if err == nil && !membership.Start.IsZero() && !membership.End.IsZero() && membership.End.Before(membership.Start) {
+		err = fmt.Errorf("membership_end %s is before membership_start %s", membership.End.Format("2006-01-02"), membership.Start.Format("2006-01-02"))
+	}
+
+	return membership, err
+}
+
+func ParseMembershipRecurring(recurring string) bool {
+	switch strings.TrimSpace(strings.ToLower(recurring)) {
+	case "yes", "y", "true", "1", "on":
+		return true
+	}
+
+	return false
+}
+
+// lifetime memberships never end and honorary ones only end if given an end date
+func (m Membership) openEnded() bool {
+	return m.Tier == MembershipLifetime || (m.Tier == MembershipHonorary && m.End.IsZero())
+}
+
+// IsActive reports whether the membership covered asOf. Memberships run through their end date and
+// open ended ones never lapse. The start date is not checked: Stripe keeps only the latest period,
+// so a member who renewed early has a membership_start after today.
+func (m Membership) IsActive(asOf time.Time) bool {
+	if m.Tier == MembershipUnknown {
+		return false
+	}
+	if m.openEnded() {
+		return true
+	}
+
+	return m.End.After(MembershipExpiryCutoff(asOf))
+}
+
+// StartsAfter reports a known membership_start after asOf. For a backdated report it means the
+// member had not joined yet, or that the period asOf fell in was replaced by a renewal.
+func (m Membership) StartsAfter(asOf time.Time) bool {
+	return !m.Start.IsZero() && m.Start.After(asOf)
+}
+
+// ActiveOn is IsActive for reports that may be backdated. Before today a membership_start after asOf
+// means the member had not joined yet; from today on it is an early renewal and still counts.
+func (m Membership) ActiveOn(asOf time.Time) bool {
+	backdated := asOf.Format("2006-01-02") < time.Now().Format("2006-01-02")
+
+	return m.IsActive(asOf) && !(backdated && m.StartsAfter(asOf))
+}
+
+// MembershipExpiryCutoff is the time a membership_end must be after to still count on asOf
+func MembershipExpiryCutoff(asOf time.Time) time.Time {
+	return asOf.AddDate(0, 0, -1)
+}
@@ -0,0 +1,180 @@
+package shared
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const MembershipSourceCopper = "copper"
+
+// CopperOption is the CP_person_membership dropdown option for the tier, 0 if Copper has none
+func (t MembershipTier) CopperOption() int {
+	switch t {
+	case MembershipOneYear:
+		return CP_person_membership_option_oneyear
+	case MembershipTwoYear:
+		return CP_person_membership_option_twoyear
+	case MembershipLifetime:
+		return CP_person_membership_option_lifetime
+	case MembershipComplimentary:
+		return CP_person_membership_option_complimentary
+	case MembershipStudent:
+		return CP_person_membership_option_student
+	case MembershipHonorary:
+		return CP_person_membership_option_honorary
+	}
+
+	return 0
+}
+
+func MembershipTierFromCopperOption(option int) MembershipTier {
+	for _, tier := range AllMembershipTiers {
+		if tier.CopperOption() == option {
+			return tier
+		}
+	}
+
+	return MembershipUnknown
+}
+
+// MembershipFromCopper reads the membership custom fields of a person,
+// returning false when the membership dropdown is not set
+func MembershipFromCopper(person CopperPerson) (Membership, bool) {
+	membership := Membership{Source: MembershipSourceCopper}
+
+	option, ok := CopperIntValue(CopperGetCustomFieldValue(person.CustomFields, CP_person_membership))
+	if !ok {
+		return membership, false
+	}
+	membership.Tier = MembershipTierFromCopperOption(option)
+	membership.Type = membership.Tier.String()
+
+	if start, ok := CopperIntValue(CopperGetCustomFieldValue(person.CustomFields, CP_person_membership_start)); ok {
+		membership.Start = time.Unix(int64(start), 0).UTC()
+	}
+	if end, ok := CopperIntValue(CopperGetCustomFieldValue(person.CustomFields, CP_person_membership_end)); ok {
+		membership.End = time.Unix(int64(end), 0).UTC()
+	}
+
+	return membership, true
+}
+
+func CopperStripeNumber(person CopperPerson) string {
+	value := CopperGetCustomFieldValue(person.CustomFields, CP_person_stripe_number)
+	if value == nil {
+		return ""
+	}
+
+	return strings.TrimSpace(CopperStringValue(value))
+}
+
+// CopperIntValue converts dropdown option ids and date timestamps, which arrive as json numbers
+func CopperIntValue(value interface{}) (int, bool) {
+	switch v := value.(type) {
+	case float64:
+		return int(v), true
+	case int:
+		return v, true
+	case int64:
+		return int(v), true
+	case json.Number:
+		i, err := v.Int64()
+		return int(i), err == nil
+	case string:
+		i, err := strconv.Atoi(strings.TrimSpace(v))
+		return i, err == nil
+	}
+
+	return 0, false
+}
+
+func CopperStringValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	body, _ := json.Marshal(value)
+
+	return string(body)
+}
+
+// SameMembershipDate compares dates that may have been stored at midnight in different time zones
+func SameMembershipDate(a time.Time, b time.Time) bool {
+	if a.IsZero() || b.IsZero() {
+		return a.IsZero() == b.IsZero()
+	}
+	diff := a.Sub(b)
+
+	return diff < 24*time.Hour && diff > -24*time.Hour
+}
+
+// FormatMembershipDate is blank for a missing date
+func FormatMembershipDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.Format("2006-01-02")
+}
+
+// CopperMembershipPurchase is a won opportunity in the membership pipeline
+type CopperMembershipPurchase struct {
+	OpportunityID int
+	PersonID      int
+	Name          string
+	Closed        time.Time
+	End           time.Time
+}
+
+// Covers reports whether the purchase paid for membership on t. Purchases without an end date
+// are taken to last one year, or two when the opportunity name says so.
+func (p CopperMembershipPurchase) Covers(t time.Time) bool {
+	if p.Closed.IsZero() || p.Closed.After(t) {
+		return false
+	}
+	end := p.End
+	if end.IsZero() {
+		years := 1
+		if ParseMembershipTier(p.Name) == MembershipTwoYear {
+			years = 2
+		}
+		end = p.Closed.AddDate(years, 0, 0)
+	}
+
+	return end.After(MembershipExpiryCutoff(t))
+}
+
+// CopperListMembershipPurchases pages through every won membership opportunity, grouped by primary contact
+func CopperListMembershipPurchases() (map[int][]CopperMembershipPurchase, error) {
+	purchases := make(map[int][]CopperMembershipPurchase)
+	for page := 1; ; page++ {
+		opps, err := CopperListOpportunities(page, []int{CP_opportunity_pipeline_id_membership}, []int{1})
+		if err != nil {
+			return purchases, err
+		}
+		for _, opp := range opps {
+			person_id, ok := CopperIntValue(opp.PrimaryContactID)
+			if !ok {
+				continue
+			}
+			purchase := CopperMembershipPurchase{OpportunityID: opp.ID, PersonID: person_id, Name: opp.Name}
+			purchase.Closed, _ = StringToDateTimeHelper(opp.CloseDate)
+			if end, ok := CopperIntValue(CopperGetCustomFieldValue(opp.CustomFields, CP_opportunity_end_date)); ok {
+				purchase.End = time.Unix(int64(end), 0).UTC()
+			}
+			purchases[person_id] = append(purchases[person_id], purchase)
+		}
+		if len(opps) < 100 {
+			break
+		}
+		time.Sleep(CP_cache_page_delay)
+	}
+
+	return purchases, nil
+}
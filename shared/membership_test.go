@@ -0,0 +1,104 @@
+package shared_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/owasp-foundation/admin-local-go/shared"
+)
+
+func TestParseMembershipTier(t *testing.T) {
+	tests := []struct {
+		membership_type string
+		want            shared.MembershipTier
+	}{
+		{"one", shared.MembershipOneYear},
+		{"One Year", shared.MembershipOneYear},
+		{" ONE-YEAR ", shared.MembershipOneYear},
+		{"1 year", shared.MembershipOneYear},
+		{"1yr", shared.MembershipOneYear},
+		{"OWASP Membership - One Year", shared.MembershipOneYear},
+		{"two", shared.MembershipTwoYear},
+		{"two-year", shared.MembershipTwoYear},
+		{"2 Years", shared.MembershipTwoYear},
+		{"oneyear", shared.MembershipOneYear},
+		{"twoyear", shared.MembershipTwoYear},
+		{"1 years", shared.MembershipOneYear},
+		{"Two Year (upgraded from one)", shared.MembershipTwoYear},
+		{"one year, renewed as two", shared.MembershipOneYear},
+		{"Complimentary One Year", shared.MembershipOneYear},
+		{"comp", shared.MembershipComplimentary},
+		{"lifetime", shared.MembershipLifetime},
+		{"Lifetime Membership", shared.MembershipLifetime},
+		{"lifetime (was one year)", shared.MembershipLifetime},
+		{"complimentary", shared.MembershipComplimentary},
+		{"student", shared.MembershipStudent},
+		{"Honorary", shared.MembershipHonorary},
+		// substrings of other words used to match
+		{"none", shared.MembershipUnknown},
+		{"someone", shared.MembershipUnknown},
+		{"network", shared.MembershipUnknown},
+		{"gone", shared.MembershipUnknown},
+		{"Invoice 1 of 2", shared.MembershipUnknown},
+		{"", shared.MembershipUnknown},
+	}
+	for _, tt := range tests {
+		if got := shared.ParseMembershipTier(tt.membership_type); got != tt.want {
+			t.Errorf("%q: got %s, want %s", tt.membership_type, got, tt.want)
+		}
+	}
+}
+
+func TestMembershipIsActive(t *testing.T) {
+	asof := time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+	date := func(s string) time.Time {
+		d, _ := time.Parse("2006-01-02", s)
+		return d
+	}
+	tests := []struct {
+		name         string
+		membership   shared.Membership
+		active       bool
+		starts_after bool
+	}{
+		{"current", shared.Membership{Tier: shared.MembershipOneYear, Start: date("2024-01-01"), End: date("2025-01-01")}, true, false},
+		{"ends today", shared.Membership{Tier: shared.MembershipOneYear, End: date("2024-06-15")}, true, false},
+		{"ended", shared.Membership{Tier: shared.MembershipOneYear, End: date("2024-06-13")}, false, false},
+		// Stripe only keeps the renewed period, which has not started yet
+		{"renewed early", shared.Membership{Tier: shared.MembershipOneYear, Start: date("2024-07-01"), End: date("2025-07-01")}, true, true},
+		{"lifetime", shared.Membership{Tier: shared.MembershipLifetime, Start: date("2020-01-01")}, true, false},
+		{"honorary without end", shared.Membership{Tier: shared.MembershipHonorary}, true, false},
+		{"unknown", shared.Membership{End: date("2025-01-01")}, false, false},
+	}
+	for _, tt := range tests {
+		if got := tt.membership.IsActive(asof); got != tt.active {
+			t.Errorf("%s: active %v, want %v", tt.name, got, tt.active)
+		}
+		if got := tt.membership.StartsAfter(asof); got != tt.starts_after {
+			t.Errorf("%s: starts after %v, want %v", tt.name, got, tt.starts_after)
+		}
+	}
+}
+
+func TestMembershipActiveOn(t *testing.T) {
+	today := time.Now().UTC()
+	renewed := shared.Membership{Tier: shared.MembershipOneYear, Start: today.AddDate(0, 1, 0), End: today.AddDate(1, 1, 0)}
+	joined := shared.Membership{Tier: shared.MembershipOneYear, Start: today.AddDate(0, -3, 0), End: today.AddDate(0, 9, 0)}
+	tests := []struct {
+		name       string
+		membership shared.Membership
+		asof       time.Time
+		want       bool
+	}{
+		{"early renewal today", renewed, today, true},
+		{"early renewal backdated", renewed, today.AddDate(0, -1, 0), false},
+		{"member today", joined, today, true},
+		{"member backdated", joined, today.AddDate(0, -1, 0), true},
+		{"before joining", joined, today.AddDate(0, -4, 0), false},
+	}
+	for _, tt := range tests {
+		if got := tt.membership.ActiveOn(tt.asof); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package shared
+
+import (
+	"strings"
+	"unicode"
+)
+
+// PersonName is a member's name in the parts YourMembership and Copper keep
+type PersonName struct {
+	Prefix string
+	First  string
+	Middle string
+	Last   string
+	Suffix string
+}
+
+var namePrefixes = map[string]bool{
+	"mr": true, "mrs": true, "ms": true, "miss": true, "mx": true, "dr": true, "prof": true, "sir": true, "rev": true,
+}
+
+var nameSuffixes = map[string]bool{
+	"jr": true, "sr": true, "ii": true, "iii": true, "iv": true, "v": true, "phd": true, "md": true, "esq": true,
+}
+
+// particles belong to the surname that follows them: Ludwig van Beethoven, Juan de la Cruz
+var nameParticles = map[string]bool{
+	"van": true, "von": true, "de": true, "da": true, "das": true, "del": true, "della": true, "der": true, "den": true,
+	"di": true, "do": true, "dos": true, "du": true, "la": true, "le": true, "ter": true, "ten": true, "bin": true,
+	"ibn": true, "al": true,
+}
+
+func nameKey(word string) string {
+	return strings.ToLower(strings.Trim(word, ".,"))
+}
+
+// NormalizeNameCase title cases a name typed in all upper or all lower case,
+// and leaves mixed case names such as McDonald or deVries alone
+func NormalizeNameCase(name string) string {
+	name = strings.Join(strings.Fields(name), " ")
+	if name != strings.ToLower(name) && name != strings.ToUpper(name) {
+		return name
+	}
+
+	words := strings.Fields(strings.ToLower(name))
+	for i, word := range words {
+		if i < len(words)-1 && nameParticles[word] {
+			continue
+		}
+		runes := []rune(titleWord(word))
+		if len(runes) > 2 && runes[0] == 'M' && runes[1] == 'c' {
+			runes[2] = unicode.ToUpper(runes[2])
+		}
+		words[i] = string(runes)
+	}
+
+	return strings.Join(words, " ")
+}
+
+// titleWord capitalizes the first letter of a word and of each part after a hyphen, apostrophe or period
+func titleWord(word string) string {
+	runes := []rune(word)
+	upper := true
+	for j, r := range runes {
+		if upper && unicode.IsLetter(r) {
+			runes[j] = unicode.ToUpper(r)
+		}
+		upper = r == '-' || r == '\'' || r == '.'
+	}
+
+	return string(runes)
+}
+
+// TitleCase capitalizes every word of text typed in all upper or all lower case, such as a city, without
+// the surname rules of NormalizeNameCase; mixed case text is left alone
+func TitleCase(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if text != strings.ToLower(text) && text != strings.ToUpper(text) {
+		return text
+	}
+
+	words := strings.Fields(strings.ToLower(text))
+	for i, word := range words {
+		words[i] = titleWord(word)
+	}
+
+	return strings.Join(words, " ")
+}
+
+// ParsePersonName splits a single name field such as a Stripe customer name. "Last, First" is
+// understood, the surname keeps its particles, and anything between first and last is the middle name.
+func ParsePersonName(full string) PersonName {
+	name := PersonName{}
+	full = strings.Join(strings.Fields(full), " ")
+
+	if before, after, found := strings.Cut(full, ","); found {
+		after = strings.TrimSpace(after)
+		if nameSuffixes[nameKey(after)] {
+			name.Suffix = after
+			full = before
+		} else if after != "" && !strings.Contains(after, ",") {
+			full = after + " " + before
+		}
+	}
+
+	words := strings.Fields(strings.ReplaceAll(full, ",", " "))
+	for len(words) > 1 && namePrefixes[nameKey(words[0])] {
+		name.Prefix = strings.TrimSpace(name.Prefix + " " + words[0])
+		words = words[1:]
+	}
+	for len(words) > 1 && nameSuffixes[nameKey(words[len(words)-1])] {
+		name.Suffix = strings.TrimSpace(words[len(words)-1] + " " + name.Suffix)
+		words = words[:len(words)-1]
+	}
+	if len(words) == 0 {
+		return name
+	}
+	if len(words) == 1 && name.Prefix != "" { // "Mr Smith" is addressed by surname
+		name.Last = words[0]
+		return name.normalized()
+	}
+
+	name.First = words[0]
+	if len(words) > 1 {
+		last := len(words) - 1
+		for last > 1 && nameParticles[nameKey(words[last-1])] {
+			last--
+		}
+		name.Middle = strings.Join(words[1:last], " ")
+		name.Last = strings.Join(words[last:], " ")
+	}
+
+	return name.normalized()
+}
+
+func (n PersonName) normalized() PersonName {
+	return PersonName{
+		Prefix: strings.Join(strings.Fields(n.Prefix), " "),
+		First:  NormalizeNameCase(n.First),
+		Middle: NormalizeNameCase(n.Middle),
+		Last:   NormalizeNameCase(n.Last),
+		Suffix: strings.Join(strings.Fields(n.Suffix), " "),
+	}
+}
+
+func NameFromCopper(person CopperPerson) PersonName {
+	return PersonName{
+		Prefix: CopperStringValue(person.Prefix),
+		First:  person.FirstName,
+		Middle: CopperStringValue(person.MiddleName),
+		Last:   person.LastName,
+		Suffix: CopperStringValue(person.Suffix),
+	}.normalized()
+}
+
+// MemberName prefers the name parts kept in Copper, filling the ones it lacks from the Stripe customer name
+func MemberName(person CopperPerson, stripe_name string) PersonName {
+	name := NameFromCopper(person)
+	if name.First != "" && name.Last != "" {
+		return name
+	}
+
+	parsed := ParsePersonName(stripe_name)
+	if parsed.First == "" && parsed.Last == "" {
+		parsed = ParsePersonName(person.Name)
+	}
+	if name.First == "" {
+		return parsed
+	}
+	if strings.EqualFold(parsed.First, name.First) {
+		name.Last = parsed.Last
+		if name.Middle == "" {
+			name.Middle = parsed.Middle
+		}
+	} else {
+		// the Stripe name does not start with the Copper first name, so only its surname is used
+		name.Last = parsed.Last
+		if name.Last == "" {
+			name.Last = parsed.First
+		}
+	}
+
+	return name
+}
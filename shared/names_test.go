@@ -0,0 +1,116 @@
+package shared_test
+
+import (
+	"testing"
+
+	"github.com/owasp-foundation/admin-local-go/shared"
+)
+
+func TestNormalizeNameCase(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"JANE", "Jane"},
+		{"jane", "Jane"},
+		{"  mary   ann  ", "Mary Ann"},
+		{"MCDONALD", "McDonald"},
+		{"mcdonald", "McDonald"},
+		{"o'brien", "O'Brien"},
+		{"SMITH-JONES", "Smith-Jones"},
+		{"ludwig van beethoven", "Ludwig van Beethoven"},
+		{"DE LA CRUZ", "de la Cruz"},
+		{"van", "Van"},
+		{"josé", "José"},
+		{"ÉLODIE", "Élodie"},
+		{"McDonald", "McDonald"},
+		{"deVries", "deVries"},
+		{"Mc", "Mc"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := shared.NormalizeNameCase(tt.name); got != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTitleCase(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"LA PAZ", "La Paz"},
+		{"del mar", "Del Mar"},
+		{"LE MANS", "Le Mans"},
+		{"DEN HAAG", "Den Haag"},
+		{"saint-étienne", "Saint-Étienne"},
+		{"  new   york ", "New York"},
+		{"DeKalb", "DeKalb"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := shared.TitleCase(tt.text); got != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestParsePersonName(t *testing.T) {
+	tests := []struct {
+		full string
+		want shared.PersonName
+	}{
+		{"Jane Example", shared.PersonName{First: "Jane", Last: "Example"}},
+		{"Jane", shared.PersonName{First: "Jane"}},
+		{"", shared.PersonName{}},
+		{"  Jane    Q   Example ", shared.PersonName{First: "Jane", Middle: "Q", Last: "Example"}},
+		{"Mary Ann Lou Smith", shared.PersonName{First: "Mary", Middle: "Ann Lou", Last: "Smith"}},
+		{"Example, Jane", shared.PersonName{First: "Jane", Last: "Example"}},
+		{"SMITH, JOHN", shared.PersonName{First: "John", Last: "Smith"}},
+		{"de la Cruz, Juan", shared.PersonName{First: "Juan", Last: "de la Cruz"}},
+		{"Ludwig van Beethoven", shared.PersonName{First: "Ludwig", Last: "van Beethoven"}},
+		{"Juan Carlos de la Cruz", shared.PersonName{First: "Juan", Middle: "Carlos", Last: "de la Cruz"}},
+		{"John Smith Jr.", shared.PersonName{First: "John", Last: "Smith", Suffix: "Jr."}},
+		{"John Smith, Jr.", shared.PersonName{First: "John", Last: "Smith", Suffix: "Jr."}},
+		{"Dr. Jane Example PhD", shared.PersonName{Prefix: "Dr.", First: "Jane", Last: "Example", Suffix: "PhD"}},
+		{"Robert Smith III", shared.PersonName{First: "Robert", Last: "Smith", Suffix: "III"}},
+		{"ronald mcdonald", shared.PersonName{First: "Ronald", Last: "McDonald"}},
+		{"Dr.", shared.PersonName{First: "Dr."}},
+		{"Mr Smith", shared.PersonName{Prefix: "Mr", Last: "Smith"}},
+		{"Mrs. SMITH Jr.", shared.PersonName{Prefix: "Mrs.", Last: "Smith", Suffix: "Jr."}},
+	}
+	for _, tt := range tests {
+		if got := shared.ParsePersonName(tt.full); got != tt.want {
+			t.Errorf("%q: got %+v, want %+v", tt.full, got, tt.want)
+		}
+	}
+}
+
+func TestMemberName(t *testing.T) {
+	tests := []struct {
+		name       string
+		person     shared.CopperPerson
+		stripe     string
+		want_first string
+		want_last  string
+	}{
+		{"copper wins", shared.CopperPerson{FirstName: "Jane", LastName: "Example", Name: "Jane Example"}, "J Sample", "Jane", "Example"},
+		{"copper case", shared.CopperPerson{FirstName: "JANE", LastName: "EXAMPLE"}, "", "Jane", "Example"},
+		{"not in copper", shared.CopperPerson{}, "Example, Jane", "Jane", "Example"},
+		{"copper full name only", shared.CopperPerson{Name: "Juan de la Cruz"}, "", "Juan", "de la Cruz"},
+		{"copper first name only", shared.CopperPerson{FirstName: "Juan"}, "Juan de la Cruz", "Juan", "de la Cruz"},
+		{"stripe name differs", shared.CopperPerson{FirstName: "Bob"}, "Robert Smith", "Bob", "Smith"},
+		{"stripe single word", shared.CopperPerson{FirstName: "Bob"}, "Smith", "Bob", "Smith"},
+		// the last word used to be taken as the surname
+		{"suffix is not the surname", shared.CopperPerson{}, "John Smith Jr.", "John", "Smith"},
+		{"particles stay with the surname", shared.CopperPerson{}, "Ludwig van Beethoven", "Ludwig", "van Beethoven"},
+		{"no name", shared.CopperPerson{}, "", "", ""},
+	}
+	for _, tt := range tests {
+		got := shared.MemberName(tt.person, tt.stripe)
+		if got.First != tt.want_first || got.Last != tt.want_last {
+			t.Errorf("%s: got %q %q, want %q %q", tt.name, got.First, got.Last, tt.want_first, tt.want_last)
+		}
+	}
+}
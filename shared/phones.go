@@ -0,0 +1,117 @@
+package shared
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// PhoneNumber is one of a person's numbers; E164 is empty and Issue says why when it could not be normalized
+type PhoneNumber struct {
+	Raw      string
+	E164     string
+	Category string
+	Issue    string
+}
+
+var phoneExtension = regexp.MustCompile(`(?i)\s*(ext\.?|extension|x|#)\s*\d+\s*$`)
+var phoneCharacters = regexp.MustCompile(`^\+?[\d\s\-./()]+$`)
+
+// the trunk prefix written after the calling code, as in +44 (0)20 7946 0000, is not dialed
+var phoneTrunkZero = regexp.MustCompile(`\(\s*0\s*\)`)
+
+// splitCallingCode takes the calling code off the front of an international number
+func splitCallingCode(digits string) (string, string, bool) {
+	countriesOnce.Do(loadCountries)
+	for n := 1; n <= 3 && n < len(digits); n++ {
+		if callingCodes[digits[:n]] {
+			return digits[:n], digits[n:], true
+		}
+	}
+
+	return "", "", false
+}
+
+// NormalizePhoneNumber formats a number as E.164. Numbers without a + or 00 prefix are dialed from
+// country, an ISO code or name; extensions are dropped.
+func NormalizePhoneNumber(raw string, country string) (string, error) {
+	number := phoneExtension.ReplaceAllString(strings.TrimSpace(raw), "")
+	if number == "" {
+		return "", errors.New("empty")
+	}
+	if !phoneCharacters.MatchString(number) {
+		return "", errors.New("unexpected characters")
+	}
+	if strings.HasPrefix(number, "+") || strings.HasPrefix(number, "00") {
+		number = phoneTrunkZero.ReplaceAllString(number, "")
+	}
+
+	digits := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, number)
+
+	dialing, _ := LookupCountry(country)
+	var code, national string
+	international := strings.HasPrefix(number, "+")
+	if !international && strings.HasPrefix(digits, "00") {
+		international, digits = true, digits[2:]
+	} else if !international && dialing.CallingCode == "1" && strings.HasPrefix(digits, "011") {
+		international, digits = true, digits[3:]
+	}
+	if international {
+		var ok bool
+		code, national, ok = splitCallingCode(digits)
+		if !ok {
+			return "", errors.New("unknown calling code")
+		}
+	} else {
+		if dialing.CallingCode == "" {
+			return "", errors.New("no country to dial from")
+		}
+		code, national = dialing.CallingCode, digits
+		switch {
+		case code == "1" && len(national) == 11 && national[0] == '1':
+			national = national[1:]
+		case code == "7" && len(national) == 11 && national[0] == '8':
+			national = national[1:]
+		case code != "39" && strings.HasPrefix(national, "0"):
+			// the trunk prefix, except in Italy where the 0 is part of the number
+			national = national[1:]
+		}
+	}
+
+	switch {
+	case code == "1" && (len(national) != 10 || national[0] < '2'):
+		return "", errors.New("not a 10 digit North American number")
+	case code == "7" && len(national) != 10:
+		return "", errors.New("not a 10 digit number")
+	case len(code)+len(national) < 7:
+		return "", errors.New("too short")
+	case len(code)+len(national) > 15:
+		return "", errors.New("too long")
+	case code != "39" && strings.HasPrefix(national, "0"):
+		return "", errors.New("unexpected 0 after the calling code")
+	}
+
+	return "+" + code + national, nil
+}
+
+// PersonPhoneNumbers normalizes all of a person's numbers, dialing from country when they have no prefix
+func PersonPhoneNumbers(person CopperPerson, country string) []PhoneNumber {
+	numbers := make([]PhoneNumber, 0, len(person.PhoneNumbers))
+	for _, phone := range person.PhoneNumbers {
+		number := PhoneNumber{Raw: phone.Number, Category: phone.Category}
+		e164, err := NormalizePhoneNumber(phone.Number, country)
+		if err != nil {
+			number.Issue = err.Error()
+		} else {
+			number.E164 = e164
+		}
+		numbers = append(numbers, number)
+	}
+
+	return numbers
+}
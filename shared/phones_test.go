@@ -0,0 +1,41 @@
+package shared_test
+
+import (
+	"testing"
+
+	"github.com/owasp-foundation/admin-local-go/shared"
+)
+
+func TestNormalizePhoneNumber(t *testing.T) {
+	tests := []struct {
+		raw     string
+		country string
+		want    string
+		issue   string
+	}{
+		{"(217) 555-0100", "US", "+12175550100", ""},
+		{"1-217-555-0100", "United States", "+12175550100", ""},
+		{"+1 217 555 0100 ext. 12", "", "+12175550100", ""},
+		{"+44 (0)20 7946 0000", "", "+442079460000", ""},
+		{"+44(0)20 7946 0000", "US", "+442079460000", ""},
+		{"0044 (0) 20 7946 0000", "", "+442079460000", ""},
+		{"020 7946 0000", "GB", "+442079460000", ""},
+		{"+44 020 7946 0000", "", "", "unexpected 0 after the calling code"},
+		{"06 1234 5678", "Italy", "+390612345678", ""},
+		{"555-0199", "US", "", "not a 10 digit North American number"},
+		{"1234", "GB", "", "too short"},
+		{"call me", "US", "", "unexpected characters"},
+		{"2175550100", "", "", "no country to dial from"},
+		{"", "US", "", "empty"},
+	}
+	for _, tt := range tests {
+		got, err := shared.NormalizePhoneNumber(tt.raw, tt.country)
+		issue := ""
+		if err != nil {
+			issue = err.Error()
+		}
+		if got != tt.want || issue != tt.issue {
+			t.Errorf("%q from %q: got %q, %q, want %q, %q", tt.raw, tt.country, got, issue, tt.want, tt.issue)
+		}
+	}
+}
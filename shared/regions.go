@@ -0,0 +1,88 @@
+package shared
+
+// ChapterRegion is one of the CP_project_chapter_region options, as named in countries.json
+type ChapterRegion string
+
+const (
+	ChapterRegionUnknown        ChapterRegion = ""
+	ChapterRegionAfrica         ChapterRegion = "africa"
+	ChapterRegionAsia           ChapterRegion = "asia"
+	ChapterRegionCentralAmerica ChapterRegion = "central_america"
+	ChapterRegionEasternEurope  ChapterRegion = "eastern_europe"
+	ChapterRegionEuropeanUnion  ChapterRegion = "european_union"
+	ChapterRegionMiddleEast     ChapterRegion = "middle_east"
+	ChapterRegionNorthAmerica   ChapterRegion = "north_america"
+	ChapterRegionOceania        ChapterRegion = "oceania"
+	ChapterRegionSouthAmerica   ChapterRegion = "south_america"
+	ChapterRegionCaribbean      ChapterRegion = "caribbean"
+)
+
+// every region, in the order reports list them. Western Europe outside the EU, such as the UK,
+// Switzerland and Norway, counts as European Union, as its chapters do.
+var AllChapterRegions = []ChapterRegion{ChapterRegionAfrica, ChapterRegionAsia, ChapterRegionCentralAmerica, ChapterRegionEasternEurope,
+	ChapterRegionEuropeanUnion, ChapterRegionMiddleEast, ChapterRegionNorthAmerica, ChapterRegionOceania, ChapterRegionSouthAmerica, ChapterRegionCaribbean}
+
+func (r ChapterRegion) String() string {
+	switch r {
+	case ChapterRegionAfrica:
+		return "Africa"
+	case ChapterRegionAsia:
+		return "Asia"
+	case ChapterRegionCentralAmerica:
+		return "Central America"
+	case ChapterRegionEasternEurope:
+		return "Eastern Europe"
+	case ChapterRegionEuropeanUnion:
+		return "European Union"
+	case ChapterRegionMiddleEast:
+		return "Middle East"
+	case ChapterRegionNorthAmerica:
+		return "North America"
+	case ChapterRegionOceania:
+		return "Oceania"
+	case ChapterRegionSouthAmerica:
+		return "South America"
+	case ChapterRegionCaribbean:
+		return "The Caribbean"
+	}
+
+	return "Unknown"
+}
+
+// CopperOption is the CP_project_chapter_region dropdown option for the region, 0 if Copper has none
+func (r ChapterRegion) CopperOption() int {
+	switch r {
+	case ChapterRegionAfrica:
+		return CP_project_chapter_region_option_africa
+	case ChapterRegionAsia:
+		return CP_project_chapter_region_option_asia
+	case ChapterRegionCentralAmerica:
+		return CP_project_chapter_region_option_centralamerica
+	case ChapterRegionEasternEurope:
+		return CP_project_chapter_region_option_eastern_europe
+	case ChapterRegionEuropeanUnion:
+		return CP_project_chapter_region_option_european_union
+	case ChapterRegionMiddleEast:
+		return CP_project_chapter_region_option_middle_east
+	case ChapterRegionNorthAmerica:
+		return CP_project_chapter_region_option_northamerica
+	case ChapterRegionOceania:
+		return CP_project_chapter_region_option_oceania
+	case ChapterRegionSouthAmerica:
+		return CP_project_chapter_region_option_southamerica
+	case ChapterRegionCaribbean:
+		return CP_project_chapter_region_option_the_caribbean
+	}
+
+	return 0
+}
+
+// ChapterRegionForCountry finds the region of a country given by ISO code, name or alias
+func ChapterRegionForCountry(country string) ChapterRegion {
+	c, ok := LookupCountry(country)
+	if !ok {
+		return ChapterRegionUnknown
+	}
+
+	return c.ChapterRegion
+}
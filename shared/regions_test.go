@@ -0,0 +1,42 @@
+package shared_test
+
+import (
+	"testing"
+
+	"github.com/owasp-foundation/admin-local-go/shared"
+)
+
+func TestChapterRegionForCountry(t *testing.T) {
+	tests := []struct {
+		country string
+		want    shared.ChapterRegion
+	}{
+		{"Nigeria", shared.ChapterRegionAfrica},
+		{"IN", shared.ChapterRegionAsia},
+		{"Costa Rica", shared.ChapterRegionCentralAmerica},
+		{"Ukraine", shared.ChapterRegionEasternEurope},
+		{"DEU", shared.ChapterRegionEuropeanUnion},
+		{"Israel", shared.ChapterRegionMiddleEast},
+		{"USA", shared.ChapterRegionNorthAmerica},
+		{"New Zealand", shared.ChapterRegionOceania},
+		{"Brasil", shared.ChapterRegionSouthAmerica},
+		{"Jamaica", shared.ChapterRegionCaribbean},
+		// Western Europe outside the EU counts as European Union
+		{"United Kingdom", shared.ChapterRegionEuropeanUnion},
+		{"Switzerland", shared.ChapterRegionEuropeanUnion},
+		// island nations and territories are Caribbean, the isthmus is Central America
+		{"Dominican Republic", shared.ChapterRegionCaribbean},
+		{"Puerto Rico", shared.ChapterRegionCaribbean},
+		{"Belize", shared.ChapterRegionCentralAmerica},
+		{"Panama", shared.ChapterRegionCentralAmerica},
+		{"Mexico", shared.ChapterRegionNorthAmerica},
+		{"Antarctica", shared.ChapterRegionUnknown},
+		{"Freedonia", shared.ChapterRegionUnknown},
+		{"", shared.ChapterRegionUnknown},
+	}
+	for _, tt := range tests {
+		if got := shared.ChapterRegionForCountry(tt.country); got != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.country, got, tt.want)
+		}
+	}
+}
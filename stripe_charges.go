@@ -0,0 +1,95 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	"github.com/owasp-foundation/admin-local-go/shared"
+	"github.com/stripe/stripe-go/v73"
+	"github.com/stripe/stripe-go/v73/charge"
+)
+
+// membership_charge is a successful charge for a membership, with the tier it bought
+type membership_charge struct {
+	charge *stripe.Charge
+	tier   shared.MembershipTier
+}
+
+// charge_membership_type finds what a charge bought: its own metadata, then the invoice lines of a
+// subscription renewal, then the description, then the customer's current membership
+func charge_membership_type(ch *stripe.Charge) string {
+	if membership_type := strings.TrimSpace(ch.Metadata["membership_type"]); membership_type != "" {
+		return membership_type
+	}
+	if ch.Invoice != nil && ch.Invoice.Lines != nil {
+		for _, line := range ch.Invoice.Lines.Data {
+			if shared.ParseMembershipTier(line.Description) != shared.MembershipUnknown {
+				return line.Description
+			}
+		}
+	}
+	if shared.ParseMembershipTier(ch.Description) != shared.MembershipUnknown {
+		return ch.Description
+	}
+	if ch.Customer != nil {
+		return ch.Customer.Metadata["membership_type"]
+	}
+
+	return ""
+}
+
+// membership_metadata is true for the metadata the membership checkout puts on what it creates
+func membership_metadata(metadata map[string]string) bool {
+	return strings.EqualFold(strings.TrimSpace(metadata["purchase_type"]), "membership") || strings.TrimSpace(metadata["membership_type"]) != ""
+}
+
+func is_membership_charge(ch *stripe.Charge) bool {
+	if membership_metadata(ch.Metadata) {
+		return true
+	}
+	if strings.Contains(strings.ToLower(ch.Description), "membership") {
+		return true
+	}
+	if ch.Invoice != nil && ch.Invoice.Lines != nil {
+		for _, line := range ch.Invoice.Lines.Data {
+			if strings.Contains(strings.ToLower(line.Description), "membership") {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func charge_refund_status(ch *stripe.Charge) string {
+	if ch.AmountRefunded == 0 {
+		return "none"
+	} else if ch.Refunded || ch.AmountRefunded >= ch.Amount {
+		return "full"
+	}
+
+	return "partial"
+}
+
+// list_membership_charges returns the successful membership charges created in [from, to)
+func list_membership_charges(from time.Time, to time.Time) ([]membership_charge, error) {
+	stripe.Key = shared.GetConfigValue("STRIPE_SECRET", "")
+	params := &stripe.ChargeListParams{
+		CreatedRange: &stripe.RangeQueryParams{GreaterThanOrEqual: from.Unix(), LesserThan: to.Unix()},
+	}
+	params.AddExpand("data.customer")
+	params.AddExpand("data.invoice")
+	params.AddExpand("data.dispute")
+
+	charges := make([]membership_charge, 0)
+	iter := charge.List(params)
+	for iter.Next() {
+		ch := iter.Charge()
+		if ch.Status != stripe.ChargeStatusSucceeded || !is_membership_charge(ch) {
+			continue
+		}
+		charges = append(charges, membership_charge{ch, shared.ParseMembershipTier(charge_membership_type(ch))})
+	}
+
+	return charges, iter.Err()
+}
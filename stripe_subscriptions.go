@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/owasp-foundation/admin-local-go/shared"
+	"github.com/stripe/stripe-go/v73"
+	"github.com/stripe/stripe-go/v73/invoice"
+	"github.com/stripe/stripe-go/v73/product"
+	"github.com/stripe/stripe-go/v73/subscription"
+)
+
+// what Stripe billing says about a customer's membership renewal, as opposed to membership_recurring
+type subscription_status struct {
+	SubscriptionID string
+	AutoRenewing   bool
+	NextRenewal    time.Time
+	PaymentStatus  string
+}
+
+var (
+	products      = make(map[string]*stripe.Product)
+	products_lock sync.Mutex
+)
+
+// get_product returns the product with its name and metadata, which subscription items only have the id of
+func get_product(p *stripe.Product) *stripe.Product {
+	if p == nil || p.Name != "" {
+		return p
+	}
+	products_lock.Lock()
+	defer products_lock.Unlock()
+	if cached, ok := products[p.ID]; ok {
+		return cached
+	}
+	full, err := product.Get(p.ID, nil)
+	if err != nil {
+		fmt.Println("Could not get product " + p.ID + ": " + err.Error())
+		return p
+	}
+	products[p.ID] = full
+
+	return full
+}
+
+// is_membership_subscription matches subscriptions the way is_membership_charge matches charges, so a
+// recurring donation does not count as an auto renewing membership
+func is_membership_subscription(sub *stripe.Subscription) bool {
+	if membership_metadata(sub.Metadata) || strings.Contains(strings.ToLower(sub.Description), "membership") {
+		return true
+	}
+	if sub.Items == nil {
+		return false
+	}
+	for _, item := range sub.Items.Data {
+		if item.Price == nil {
+			continue
+		}
+		if membership_metadata(item.Price.Metadata) || strings.Contains(strings.ToLower(item.Price.Nickname+" "+item.Price.LookupKey), "membership") {
+			return true
+		}
+		if p := get_product(item.Price.Product); p != nil && (membership_metadata(p.Metadata) || strings.Contains(strings.ToLower(p.Name), "membership")) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// list_customer_subscriptions returns a customer's membership subscriptions, including canceled ones
+func list_customer_subscriptions(customer_id string) ([]*stripe.Subscription, error) {
+	params := &stripe.SubscriptionListParams{
+		Customer: stripe.String(customer_id),
+		Status:   stripe.String("all"),
+	}
+	params.AddExpand("data.latest_invoice")
+
+	subscriptions := make([]*stripe.Subscription, 0)
+	iter := subscription.List(params)
+	for iter.Next() {
+		if is_membership_subscription(iter.Subscription()) {
+			subscriptions = append(subscriptions, iter.Subscription())
+		}
+	}
+
+	return subscriptions, iter.Err()
+}
+
+// subscription_renews is true for a subscription Stripe will charge again at the end of the period
+func subscription_renews(sub *stripe.Subscription) bool {
+	switch sub.Status {
+	case stripe.SubscriptionStatusActive, stripe.SubscriptionStatusTrialing, stripe.SubscriptionStatusPastDue:
+		return !sub.CancelAtPeriodEnd
+	}
+
+	return false
+}
+
+// get_subscription_status picks the renewing subscription if there is one, else the most recent one,
+// and falls back to the latest invoice for the payment status of customers without subscriptions
+func get_subscription_status(customer_id string) (subscription_status, error) {
+	status := subscription_status{}
+	subscriptions, err := list_customer_subscriptions(customer_id)
+	if err != nil {
+		return status, err
+	}
+
+	var chosen *stripe.Subscription
+	for _, sub := range subscriptions {
+		if chosen == nil || (subscription_renews(sub) && !subscription_renews(chosen)) ||
+			(subscription_renews(sub) == subscription_renews(chosen) && sub.Created > chosen.Created) {
+			chosen = sub
+		}
+	}
+
+	if chosen != nil {
+		status.SubscriptionID = chosen.ID
+		status.AutoRenewing = subscription_renews(chosen)
+		if status.AutoRenewing {
+			status.NextRenewal = time.Unix(chosen.CurrentPeriodEnd, 0).UTC()
+		}
+		if chosen.Status == stripe.SubscriptionStatusPastDue || chosen.Status == stripe.SubscriptionStatusUnpaid {
+			status.PaymentStatus = string(chosen.Status)
+		} else if chosen.LatestInvoice != nil {
+			status.PaymentStatus = string(chosen.LatestInvoice.Status)
+		}
+		return status, nil
+	}
+
+	params := &stripe.InvoiceListParams{Customer: stripe.String(customer_id)}
+	params.Limit = stripe.Int64(1)
+	iter := invoice.List(params)
+	if iter.Next() {
+		status.PaymentStatus = string(iter.Invoice().Status)
+	}
+
+	return status, iter.Err()
+}
+
+func fill_subscription_row_data(row []string, current *stripe.Customer, membership shared.Membership) []string {
+	status, err := get_subscription_status(current.ID)
+	if err != nil {
+		fmt.Println("Could not get subscriptions for " + current.ID + ": " + err.Error())
+		return append(row, "", "", "", "")
+	}
+
+	row = append(row, strconv.FormatBool(status.AutoRenewing))
+	row = append(row, shared.FormatMembershipDate(status.NextRenewal))
+	row = append(row, status.PaymentStatus)
+	row = append(row, strconv.FormatBool(status.AutoRenewing == membership.Recurring))
+
+	return row
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stripe/stripe-go/v73"
+)
+
+func TestIsMembershipSubscription(t *testing.T) {
+	with_price := func(price *stripe.Price) *stripe.Subscription {
+		return &stripe.Subscription{Items: &stripe.SubscriptionItemList{Data: []*stripe.SubscriptionItem{{Price: price}}}}
+	}
+	tests := []struct {
+		name string
+		sub  *stripe.Subscription
+		want bool
+	}{
+		{"metadata", &stripe.Subscription{Metadata: map[string]string{"purchase_type": "membership"}}, true},
+		{"membership_type metadata", &stripe.Subscription{Metadata: map[string]string{"membership_type": "one"}}, true},
+		{"product", with_price(&stripe.Price{Product: &stripe.Product{ID: "prod_1", Name: "OWASP Membership - One Year"}}), true},
+		{"price nickname", with_price(&stripe.Price{Nickname: "Annual membership", Product: &stripe.Product{ID: "prod_2", Name: "Dues"}}), true},
+		{"donation", with_price(&stripe.Price{Product: &stripe.Product{ID: "prod_3", Name: "Monthly donation"}}), false},
+		{"donation metadata", &stripe.Subscription{Metadata: map[string]string{"purchase_type": "donation"}}, false},
+		{"no items", &stripe.Subscription{}, false},
+	}
+	for _, tt := range tests {
+		if got := is_membership_subscription(tt.sub); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}